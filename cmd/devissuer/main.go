@@ -0,0 +1,205 @@
+// Command devissuer is a stand-in OpenID Connect provider for local
+// development. It signs in whoever types an email address, so never expose
+// it. Point the app at it with:
+//
+//	"oidc_providers": [{
+//	  "name": "dev", "display_name": "Dev issuer",
+//	  "issuer": "http://localhost:9999",
+//	  "client_id": "dev", "client_secret": "dev-secret"
+//	}]
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "dev"
+
+type grant struct {
+	clientID, redirectURI, challenge, nonce string
+	email, name                             string
+	verified                                bool
+	expires                                 time.Time
+}
+
+type issuer struct {
+	url          string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+var form = template.Must(template.New("form").Parse(`<!doctype html>
+<title>Dev issuer</title>
+<h1>Dev issuer sign-in</h1>
+<form method="post">
+{{range $k, $v := .}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+{{end}}<p><label>Email <input name="email" type="email" required autofocus></label>
+<p><label>Name <input name="name"></label>
+<p><label><input name="email_verified" type="checkbox" checked> Email verified</label>
+<p><button>Sign in</button>
+</form>`))
+
+func main() {
+	addr := flag.String("addr", ":9999", "listen address")
+	issuerURL := flag.String("issuer", "http://localhost:9999", "issuer URL as seen by the app")
+	clientID := flag.String("client-id", "dev", "accepted client_id")
+	clientSecret := flag.String("client-secret", "dev-secret", "accepted client_secret")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	is := &issuer{url: strings.TrimRight(*issuerURL, "/"), clientID: *clientID, clientSecret: *clientSecret, key: key, grants: map[string]grant{}}
+
+	http.HandleFunc("/.well-known/openid-configuration", is.discovery)
+	http.HandleFunc("/authorize", is.authorize)
+	http.HandleFunc("/token", is.token)
+	http.HandleFunc("/jwks", is.jwks)
+	log.Printf("dev OIDC issuer %s listening on %s", is.url, *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func (is *issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                is.url,
+		"authorization_endpoint":                is.url + "/authorize",
+		"token_endpoint":                        is.url + "/token",
+		"jwks_uri":                              is.url + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize shows a form on GET and, on POST, redirects back with a code.
+func (is *issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+	if q.Get("client_id") != is.clientID || q.Get("response_type") != "code" || q.Get("redirect_uri") == "" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "PKCE with S256 required", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet {
+		params := url.Values{}
+		for _, k := range []string{"client_id", "response_type", "redirect_uri", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			params.Set(k, q.Get(k))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = form.Execute(w, params)
+		return
+	}
+	code := randomString()
+	is.mu.Lock()
+	is.grants[code] = grant{
+		clientID: q.Get("client_id"), redirectURI: q.Get("redirect_uri"),
+		challenge: q.Get("code_challenge"), nonce: q.Get("nonce"),
+		email: q.Get("email"), name: q.Get("name"), verified: q.Get("email_verified") != "",
+		expires: time.Now().Add(time.Minute),
+	}
+	is.mu.Unlock()
+	back, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "bad redirect_uri", http.StatusBadRequest)
+		return
+	}
+	bq := back.Query()
+	bq.Set("code", code)
+	bq.Set("state", q.Get("state"))
+	back.RawQuery = bq.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (is *issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+	if id != is.clientID || secret != is.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.Form.Get("code")
+	is.mu.Lock()
+	g, found := is.grants[code]
+	delete(is.grants, code)
+	is.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !found || time.Now().After(g.expires) || g.redirectURI != r.Form.Get("redirect_uri") ||
+		g.challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": is.url, "sub": "dev|" + strings.ToLower(g.email), "aud": g.clientID,
+		"iat": now.Unix(), "exp": now.Add(5 * time.Minute).Unix(), "nonce": g.nonce,
+		"email": g.email, "email_verified": g.verified, "name": g.name,
+	})
+	t.Header["kid"] = keyID
+	idToken, err := t.SignedString(is.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(), "token_type": "Bearer", "expires_in": 300, "id_token": idToken,
+	})
+}
+
+func (is *issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := is.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA", "kid": keyID, "alg": "RS256", "use": "sig",
+		"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -11,6 +11,7 @@ require (
 	gofr.dev v1.44.1
 	golang.org/x/crypto v0.41.0
 	google.golang.org/api v0.248.0
+	google.golang.org/grpc v1.74.2
 )
 
 require (
@@ -103,7 +104,6 @@ require (
 	google.golang.org/genproto v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250818200422-3122310a409c // indirect
-	google.golang.org/grpc v1.74.2 // indirect
 	google.golang.org/protobuf v1.36.7 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	modernc.org/libc v1.66.3 // indirect
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"finalapp/mailer"
+	"finalapp/models"
+	"finalapp/oidc"
+	"finalapp/store"
+
+	"cloud.google.com/go/firestore"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+	errAccountExists      = errors.New("account exists, sign in")
+	errAlreadyJoined      = errors.New("account already belongs to the Neighbour network")
+	errInvalidEmail       = errors.New("invalid email address")
+	errEmailNotVerified   = errors.New("the provider has not verified this email address")
+	errWeakPassword       = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+)
+
+const minPasswordLength = 8
+
+// validatePassword is the rule for every password a user chooses, at sign-up
+// and on reset. Passwords set before it existed still sign in.
+func validatePassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return errWeakPassword
+	}
+	return nil
+}
+
+// Accounts is the single identity service behind both the community feed and
+// the Neighbour helper network. MongoDB users are the source of truth and the
+// JWT user_id is always their ObjectID hex. The Firestore users collection
+// keeps the helper-network profile (location, reward, FCM token), keyed by
+// email and linked back through NUser.AccountID and User.NeighbourID.
+//
+// Verification and password-reset links are sent through mail and point at
+// baseURL, the public address of the web app.
+type Accounts struct {
+	users    store.UserRepository
+	tokens   store.TokenRepository
+	actions  store.AccountTokenRepository
+	attempts store.LoginAttemptRepository
+	mail     mailer.Mailer
+	baseURL  string
+}
+
+func NewAccounts(repos store.Repositories, m mailer.Mailer, baseURL string) *Accounts {
+	return &Accounts{
+		users:    repos.Users,
+		tokens:   repos.Tokens,
+		actions:  repos.AccountTokens,
+		attempts: repos.LoginAttempts,
+		mail:     m,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// Register creates an account. role is empty for community sign-ups. An
+// email that already has an account is refused whatever the password: the
+// owner signs in instead and joins the Neighbour network with JoinNeighbour,
+// so sign-up never authenticates anyone.
+func (a *Accounts) Register(ctx context.Context, name, email, password, role string) (*models.User, error) {
+	email = normalizeEmail(email)
+	if !validEmail(email) {
+		return nil, errInvalidEmail
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	if _, err := a.users.FindByEmail(ctx, email); err == nil {
+		return nil, errAccountExists
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := models.User{Name: name, Email: email, Password: string(hash), Role: role, CreatedAt: time.Now()}
+	if role != "" {
+		user.NeighbourID = email
+	}
+	if err := a.users.Create(ctx, &user); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			return nil, errAccountExists
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// JoinNeighbour gives a signed-in account without a Neighbour role the role
+// it asked for. An account that already has a role or a Neighbour profile
+// keeps it.
+func (a *Accounts) JoinNeighbour(ctx context.Context, user *models.User, role string) error {
+	if user.Role != "" || user.NeighbourID != "" {
+		return errAlreadyJoined
+	}
+	if err := a.users.LinkNeighbour(ctx, user.ID.Hex(), user.Email, role); err != nil {
+		return err
+	}
+	user.NeighbourID, user.Role = user.Email, role
+	return nil
+}
+
+// Authenticate checks an email and password. Legacy SHA-256 hashes are
+// upgraded to bcrypt on success, and Neighbour users who predate unified
+// accounts are adopted from Firestore on their first sign-in.
+func (a *Accounts) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	user, err := a.users.FindByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, store.ErrNotFound) {
+		return a.adoptNeighbour(ctx, email, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, legacy := verifyPassword(user.Password, password)
+	if !ok {
+		return nil, errInvalidCredentials
+	}
+	if legacy {
+		// A failed upgrade is retried on the next sign-in.
+		if hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err == nil {
+			_ = a.users.SetPasswordHash(ctx, user.ID.Hex(), string(hash))
+		}
+	}
+	return user, nil
+}
+
+// SignInExternal returns the account for an OpenID Connect identity. An
+// identity seen before maps to the account it was linked to; otherwise it is
+// linked to the account with the same email, or a new account is created.
+// Linking by email is only safe because the provider vouches for the
+// address, so unverified emails are refused, and an account whose own email
+// was never verified is taken over by the provider's user (claimUnverified).
+func (a *Accounts) SignInExternal(ctx context.Context, c *oidc.Claims) (*models.User, error) {
+	user, err := a.users.FindByExternalLogin(ctx, c.Issuer, c.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if !c.EmailVerified {
+		return nil, errEmailNotVerified
+	}
+	email := normalizeEmail(c.Email)
+	if !validEmail(email) {
+		return nil, errInvalidEmail
+	}
+	user, err = a.users.FindByEmail(ctx, email)
+	if errors.Is(err, store.ErrNotFound) {
+		name := c.Name
+		if name == "" {
+			name = email[:strings.Index(email, "@")]
+		}
+		// No password: the account signs in through the provider until the
+		// user sets one with a password reset.
+		user = &models.User{Name: name, Email: email, EmailVerified: true, CreatedAt: time.Now()}
+		err = a.users.Create(ctx, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+	login := models.ExternalLogin{Issuer: c.Issuer, Subject: c.Subject}
+	if err := a.users.AddExternalLogin(ctx, user.ID.Hex(), login); err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified {
+		if err := a.claimUnverified(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	user.ExternalLogins = append(user.ExternalLogins, login)
+	return user, nil
+}
+
+// claimUnverified hands an account whose email was never confirmed to the
+// provider-verified owner of that address. Whoever registered it may not
+// have been the owner, so their password, second factor and sessions stop
+// working; the owner can set a password with a reset.
+func (a *Accounts) claimUnverified(ctx context.Context, user *models.User) error {
+	id := user.ID.Hex()
+	if err := a.users.SetPasswordHash(ctx, id, ""); err != nil {
+		return err
+	}
+	if user.TwoFactor != nil {
+		if err := a.users.SetTwoFactor(ctx, id, nil); err != nil {
+			return err
+		}
+	}
+	if err := a.tokens.RevokeUser(ctx, id, time.Now()); err != nil {
+		return err
+	}
+	if err := a.users.SetEmailVerified(ctx, id); err != nil {
+		return err
+	}
+	user.Password, user.TwoFactor, user.EmailVerified = "", nil, true
+	return nil
+}
+
+// adoptNeighbour creates an account for a Firestore-only Neighbour user whose
+// password checks out.
+func (a *Accounts) adoptNeighbour(ctx context.Context, email, password string) (*models.User, error) {
+	client := initFirestore()
+	if client == nil {
+		return nil, errInvalidCredentials
+	}
+	defer client.Close()
+	doc, err := client.Collection("users").Doc(email).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nu NUser
+	if err := doc.DataTo(&nu); err != nil {
+		return nil, err
+	}
+	if ok, _ := verifyPassword(nu.Password, password); !ok {
+		return nil, errInvalidCredentials
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return a.link(ctx, client, doc.Ref.ID, nu, string(hash))
+}
+
+// MigrateNeighbours links every Firestore Neighbour user to an account with
+// the same email, creating accounts (with the existing password hash) where
+// none exist. It is idempotent and safe to re-run; a re-run also moves help
+// requests that still name users by profile ID over to their account IDs.
+func (a *Accounts) MigrateNeighbours(ctx context.Context) (linked, created int, err error) {
+	client := initFirestore()
+	if client == nil {
+		return 0, 0, fmt.Errorf("firestore not configured")
+	}
+	defer client.Close()
+	iter := client.Collection("users").Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return linked, created, nil
+		}
+		if err != nil {
+			return linked, created, err
+		}
+		var nu NUser
+		if err := doc.DataTo(&nu); err != nil || nu.Email == "" {
+			continue
+		}
+		_, findErr := a.users.FindByEmail(ctx, normalizeEmail(nu.Email))
+		if _, err := a.link(ctx, client, doc.Ref.ID, nu, nu.Password); err != nil {
+			return linked, created, fmt.Errorf("linking %s: %w", doc.Ref.ID, err)
+		}
+		if errors.Is(findErr, store.ErrNotFound) {
+			created++
+		} else {
+			linked++
+		}
+	}
+}
+
+// link attaches the Firestore profile docID to the account for nu.Email,
+// creating the account with passwordHash if needed, and moves the profile's
+// help requests over to the account ID.
+func (a *Accounts) link(ctx context.Context, client *firestore.Client, docID string, nu NUser, passwordHash string) (*models.User, error) {
+	email := normalizeEmail(nu.Email)
+	user, err := a.users.FindByEmail(ctx, email)
+	if errors.Is(err, store.ErrNotFound) {
+		user = &models.User{Name: nu.Name, Email: email, Password: passwordHash, CreatedAt: nu.CreatedAt}
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = time.Now()
+		}
+		err = a.users.Create(ctx, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+	role := nu.Role
+	if user.Role != "" {
+		role = user.Role
+	}
+	if err := a.users.LinkNeighbour(ctx, user.ID.Hex(), docID, role); err != nil {
+		return nil, err
+	}
+	user.NeighbourID, user.Role = docID, role
+	if _, err := client.Collection("users").Doc(docID).Update(ctx, []firestore.Update{{Path: "accountId", Value: user.ID.Hex()}}); err != nil {
+		return user, err
+	}
+	return user, relinkRequests(ctx, client, docID, user.ID.Hex())
+}
+
+// verifyPassword checks password against a stored bcrypt hash or, for
+// Neighbour accounts created before bcrypt, an unsalted SHA-256 hex digest.
+// legacy reports a successful match against the old format so the caller can
+// rehash.
+func verifyPassword(stored, password string) (ok, legacy bool) {
+	if isLegacyHash(stored) {
+		sum := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(hex.EncodeToString(sum[:]))) == 1, true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil, false
+}
+
+func isLegacyHash(stored string) bool {
+	if len(stored) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(stored)
+	return err == nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.TrimSpace(strings.ToLower(email))
+}
+
+// validEmail accepts a bare address such as "a@example.com"; display names
+// and addresses without a dotted domain are rejected.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	return strings.Contains(email[at+1:], ".")
+}
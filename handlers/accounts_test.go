@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"finalapp/mailer"
+	"finalapp/models"
+	"finalapp/oidc"
+	"finalapp/store"
+)
+
+func newTestAccounts() (*Accounts, store.Repositories) {
+	repos := store.NewMemoryRepositories()
+	return NewAccounts(repos, mailer.NewLog(io.Discard), "http://localhost"), repos
+}
+
+func TestRegisterRefusesExistingEmail(t *testing.T) {
+	ctx := context.Background()
+	a, repos := newTestAccounts()
+	user, err := a.Register(ctx, "Ann", "ann@example.com", "correct horse", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Even with the right password, sign-up must not link or authenticate.
+	for _, role := range []string{"", models.RoleElder} {
+		if _, err := a.Register(ctx, "Ann", "Ann@Example.com", "correct horse", role); !errors.Is(err, errAccountExists) {
+			t.Fatalf("Register(role %q) err = %v, want errAccountExists", role, err)
+		}
+	}
+	got, err := repos.Users.FindByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Role != "" || got.NeighbourID != "" {
+		t.Fatalf("existing account changed by sign-up: role %q, neighbour id %q", got.Role, got.NeighbourID)
+	}
+}
+
+func TestJoinNeighbour(t *testing.T) {
+	ctx := context.Background()
+	a, repos := newTestAccounts()
+	user, err := a.Register(ctx, "Bo", "bo@example.com", "correct horse", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.JoinNeighbour(ctx, user, models.RoleHelper); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := repos.Users.FindByID(ctx, user.ID.Hex())
+	if got.Role != models.RoleHelper || got.NeighbourID != "bo@example.com" {
+		t.Fatalf("after join: role %q, neighbour id %q", got.Role, got.NeighbourID)
+	}
+	if err := a.JoinNeighbour(ctx, got, models.RoleElder); !errors.Is(err, errAlreadyJoined) {
+		t.Fatalf("second join err = %v, want errAlreadyJoined", err)
+	}
+}
+
+// Someone registers the victim's address with their own password; the
+// victim later signs in through a provider that has verified the address.
+func TestSignInExternalClaimsUnverifiedAccount(t *testing.T) {
+	ctx := context.Background()
+	a, repos := newTestAccounts()
+	squatter, err := a.Register(ctx, "Mallory", "victim@example.com", "attacker pw", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := models.RefreshToken{Hash: "h", FamilyID: "f", UserID: squatter.ID.Hex(), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repos.Tokens.CreateRefresh(ctx, &rt); err != nil {
+		t.Fatal(err)
+	}
+	claims := &oidc.Claims{Issuer: "https://idp.example", Subject: "victim", Email: "victim@example.com", EmailVerified: true}
+	user, err := a.SignInExternal(ctx, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != squatter.ID || !user.EmailVerified {
+		t.Fatalf("signed in as %v (verified %v), want the existing account verified", user.ID, user.EmailVerified)
+	}
+	if _, err := a.Authenticate(ctx, "victim@example.com", "attacker pw"); !errors.Is(err, errInvalidCredentials) {
+		t.Fatalf("squatter password still works: err = %v", err)
+	}
+	if got, err := repos.Tokens.FindRefresh(ctx, "h"); err != nil || got.RevokedAt == nil {
+		t.Fatalf("squatter session not revoked: %+v, %v", got, err)
+	}
+}
+
+// A verified account keeps its password when a provider identity is linked.
+func TestSignInExternalKeepsVerifiedPassword(t *testing.T) {
+	ctx := context.Background()
+	a, repos := newTestAccounts()
+	user, err := a.Register(ctx, "Ann", "ann@example.com", "ann password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Users.SetEmailVerified(ctx, user.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	claims := &oidc.Claims{Issuer: "https://idp.example", Subject: "ann", Email: "ann@example.com", EmailVerified: true}
+	if _, err := a.SignInExternal(ctx, claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate(ctx, "ann@example.com", "ann password"); err != nil {
+		t.Fatalf("verified owner's password rejected: %v", err)
+	}
+}
+
+func TestRegisterRejectsShortPassword(t *testing.T) {
+	a, _ := newTestAccounts()
+	if _, err := a.Register(context.Background(), "Ann", "ann@example.com", "short", ""); !errors.Is(err, errWeakPassword) {
+		t.Fatalf("err = %v, want errWeakPassword", err)
+	}
+	// Length counts characters, not bytes.
+	if _, err := a.Register(context.Background(), "Ann", "ann@example.com", "ééééééé", ""); !errors.Is(err, errWeakPassword) {
+		t.Fatalf("seven two-byte characters: err = %v, want errWeakPassword", err)
+	}
+}
+
+func TestResetPasswordRejectsShortPassword(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestAccounts()
+	user, err := a.Register(ctx, "Ann", "ann@example.com", "correct horse", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := a.issueAccountToken(ctx, user.ID.Hex(), models.PurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.resetPassword(ctx, tok, "short"); !errors.Is(err, errWeakPassword) {
+		t.Fatalf("err = %v, want errWeakPassword", err)
+	}
+	// The rejected attempt did not use up the link.
+	if err := a.resetPassword(ctx, tok, "new password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate(ctx, "ann@example.com", "new password"); err != nil {
+		t.Fatalf("new password rejected: %v", err)
+	}
+}
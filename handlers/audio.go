@@ -37,6 +37,10 @@ type ElevenLabsRequest struct {
 }
 
 func AudioChatHandler(ctx *gofr.Context) (interface{}, error) {
+	// The chatbot is open to everyone, but a device key must be scoped for it.
+	if caller, ok := identity(ctx); ok && caller.DeviceID != "" && !hasScope(caller.Scopes, PermAudioChat) {
+		return nil, fmt.Errorf("403: device may not use %s", PermAudioChat)
+	}
 	var req AudioRequest
 	if err := ctx.Bind(&req); err != nil {
 		return nil, fmt.Errorf("no audio uploaded")
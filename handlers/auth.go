@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gofr.dev/pkg/gofr"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var errInvalidRefresh = errors.New("invalid refresh token")
+
+type identityKey struct{}
+
+// Identity is the caller authenticated by Tokens.Middleware.
+type Identity struct {
+	UserID    string
+	Email     string
+	Role      string
+	SessionID string // Refresh token family; empty for demo tokens
+
+	// OnBehalfOf is the elder a caregiver is acting for with a delegated
+	// token. Such tokens carry no role; Scopes lists what they may do.
+	OnBehalfOf string
+	Scopes     []Permission
+
+	// DeviceID is set for a shared device signed in with an API key. It
+	// may act only for ElderIDs, within Scopes.
+	DeviceID string
+	ElderIDs []string
+}
+
+// scoped reports whether the caller is limited to Scopes rather than a role.
+func (id Identity) scoped() bool {
+	return id.OnBehalfOf != "" || id.DeviceID != ""
+}
+
+// Subject is the Neighbour user the caller acts as: the elder for a
+// delegated token, otherwise the caller.
+func (id Identity) Subject() string {
+	if id.OnBehalfOf != "" {
+		return id.OnBehalfOf
+	}
+	return id.UserID
+}
+
+// TokenPair is returned by sign-in, sign-up and refresh.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Tokens issues short-lived access tokens and rotating refresh tokens, and
+// checks access tokens against revoked sessions.
+type Tokens struct {
+	keys        *KeySet
+	repo        store.TokenRepository
+	users       store.UserRepository
+	delegations store.DelegationRepository
+	devices     store.DeviceRepository
+}
+
+func NewTokens(repos store.Repositories, keys *KeySet) *Tokens {
+	return &Tokens{keys: keys, repo: repos.Tokens, users: repos.Users, delegations: repos.Delegations, devices: repos.Devices}
+}
+
+// Middleware validates an "Authorization: Bearer <jwt>" header, or an
+// X-Device-Key header from a shared device, and when it is good stores the
+// caller's Identity on the request context. Requests without valid
+// credentials pass through anonymously so public routes keep working;
+// handlers that need a user call currentUser.
+func (t *Tokens) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id Identity
+		var err error
+		if key := r.Header.Get(deviceKeyHeader); key != "" {
+			id, err = t.parseDevice(r.Context(), key, clientIP(r.Context()))
+		} else {
+			id, err = t.parse(r.Context(), bearerToken(r))
+		}
+		if err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Refresh exchanges a refresh token for a new pair. Presenting a token that
+// was already rotated revokes its whole family.
+func (t *Tokens) Refresh(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	pair, err := t.rotate(ctx, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errInvalidRefresh) {
+			return nil, fmt.Errorf("401: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return pair, nil
+}
+
+// Logout revokes the session named by the refresh token in the body or, if
+// none is given, the caller's current session.
+func (t *Tokens) Logout(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = ctx.Bind(&req)
+	familyID := ""
+	if req.RefreshToken != "" {
+		rt, err := t.repo.FindRefresh(ctx, hashToken(req.RefreshToken))
+		if err != nil {
+			return nil, notFoundOr500(err, "refresh token")
+		}
+		familyID = rt.FamilyID
+	} else if id, ok := identity(ctx); ok {
+		familyID = id.SessionID
+	}
+	if familyID == "" {
+		return nil, fmt.Errorf("401: invalid token")
+	}
+	if err := t.repo.RevokeFamily(ctx, familyID, time.Now()); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"message": "Logged out"}, nil
+}
+
+// issue starts a new session for the user.
+func (t *Tokens) issue(ctx context.Context, user *models.User) (TokenPair, error) {
+	return t.issueInFamily(ctx, user, randomToken(16))
+}
+
+func (t *Tokens) issueInFamily(ctx context.Context, user *models.User, familyID string) (TokenPair, error) {
+	refresh := randomToken(32)
+	now := time.Now()
+	rt := models.RefreshToken{Hash: hashToken(refresh), FamilyID: familyID, UserID: user.ID.Hex(), ExpiresAt: now.Add(refreshTokenTTL), CreatedAt: now}
+	if err := t.repo.CreateRefresh(ctx, &rt); err != nil {
+		return TokenPair{}, err
+	}
+	access, err := t.issueAccess(Identity{UserID: user.ID.Hex(), Email: user.Email, Role: user.Role, SessionID: familyID})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int(accessTokenTTL.Seconds())}, nil
+}
+
+func (t *Tokens) rotate(ctx context.Context, refresh string) (TokenPair, error) {
+	if refresh == "" {
+		return TokenPair{}, errInvalidRefresh
+	}
+	rt, err := t.repo.FindRefresh(ctx, hashToken(refresh))
+	if errors.Is(err, store.ErrNotFound) {
+		return TokenPair{}, errInvalidRefresh
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+	now := time.Now()
+	if rt.RevokedAt != nil || now.After(rt.ExpiresAt) {
+		return TokenPair{}, errInvalidRefresh
+	}
+	fresh, err := t.repo.MarkUsed(ctx, rt.ID, now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !fresh {
+		// Reuse of a rotated token means it leaked; kill the session.
+		if err := t.repo.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, errInvalidRefresh
+	}
+	user, err := t.users.FindByID(ctx, rt.UserID)
+	if err != nil {
+		return TokenPair{}, errInvalidRefresh
+	}
+	return t.issueInFamily(ctx, user, rt.FamilyID)
+}
+
+// parse validates an access token and rejects tokens whose session has been
+// revoked. A delegated token is limited to the scopes its delegation still
+// grants, so revoking or narrowing a delegation takes effect at once.
+func (t *Tokens) parse(ctx context.Context, tok string) (Identity, error) {
+	id, err := t.parseAccess(tok)
+	if err != nil {
+		return Identity{}, err
+	}
+	if id.SessionID != "" {
+		revoked, err := t.repo.FamilyRevoked(ctx, id.SessionID)
+		if err != nil {
+			return Identity{}, err
+		}
+		if revoked {
+			return Identity{}, fmt.Errorf("session revoked")
+		}
+	}
+	if id.OnBehalfOf != "" {
+		d, err := t.delegations.Active(ctx, id.OnBehalfOf, id.UserID)
+		if err != nil {
+			return Identity{}, fmt.Errorf("delegation revoked")
+		}
+		id.Scopes = intersectScopes(id.Scopes, d.Scopes)
+	}
+	return id, nil
+}
+
+// issueDelegated signs an access token letting caller act for the elder in
+// d. It shares the caller's session, so logging out ends it too.
+func (t *Tokens) issueDelegated(caller Identity, d *models.Delegation) (string, error) {
+	scopes := make([]Permission, len(d.Scopes))
+	for i, s := range d.Scopes {
+		scopes[i] = Permission(s)
+	}
+	return t.issueAccess(Identity{UserID: caller.UserID, Email: caller.Email, SessionID: caller.SessionID, OnBehalfOf: d.ElderID, Scopes: scopes})
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(h[7:])
+}
+
+// identity returns the caller set by Tokens.Middleware, if any.
+func identity(ctx *gofr.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// currentUser returns the authenticated user's ID or a 401 error. Devices
+// are not users and get a 403.
+func currentUser(ctx *gofr.Context) (string, error) {
+	id, ok := identity(ctx)
+	if !ok {
+		return "", fmt.Errorf("401: invalid token")
+	}
+	if id.DeviceID != "" {
+		return "", fmt.Errorf("403: not available to devices")
+	}
+	return id.UserID, nil
+}
+
+// issueAccess signs an access token for id with the active key.
+func (t *Tokens) issueAccess(id Identity) (string, error) {
+	claims := jwt.MapClaims{"user_id": id.UserID, "email": id.Email, "exp": time.Now().Add(accessTokenTTL).Unix()}
+	if id.Role != "" {
+		claims["role"] = id.Role
+	}
+	if id.SessionID != "" {
+		claims["sid"] = id.SessionID
+	}
+	if id.OnBehalfOf != "" {
+		claims["on_behalf_of"] = id.OnBehalfOf
+		scopes := make([]string, len(id.Scopes))
+		for i, p := range id.Scopes {
+			scopes[i] = string(p)
+		}
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	return t.keys.sign(claims)
+}
+
+func (t *Tokens) parseAccess(tok string) (Identity, error) {
+	if tok == "" {
+		return Identity{}, fmt.Errorf("token missing")
+	}
+	parsed, err := jwt.Parse(tok, t.keys.keyFunc)
+	if err != nil {
+		return Identity{}, err
+	}
+	if claims, ok := parsed.Claims.(jwt.MapClaims); ok && parsed.Valid {
+		if uid, ok := claims["user_id"].(string); ok {
+			id := Identity{UserID: uid}
+			id.Email, _ = claims["email"].(string)
+			id.Role, _ = claims["role"].(string)
+			id.SessionID, _ = claims["sid"].(string)
+			id.OnBehalfOf, _ = claims["on_behalf_of"].(string)
+			if id.OnBehalfOf != "" {
+				// Delegated tokens never carry the caregiver's own role.
+				id.Role = ""
+				scope, _ := claims["scope"].(string)
+				for _, s := range strings.Fields(scope) {
+					id.Scopes = append(id.Scopes, Permission(s))
+				}
+			}
+			return id, nil
+		}
+	}
+	return Identity{}, fmt.Errorf("invalid token claims")
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashToken(tok string) string {
+	sum := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(sum[:])
+}
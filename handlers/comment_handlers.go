@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+)
+
+const maxCommentLength = 2000
+
+func (h *StoreHandlers) CreateComment(ctx *gofr.Context) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var req struct {
+		Content  string `json:"content"`
+		ParentID string `json:"parent_id"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	content, err := commentContent(req.Content)
+	if err != nil {
+		return nil, err
+	}
+	postID := ctx.PathParam("id")
+	if _, err := h.posts.FindByID(ctx, postID); err != nil {
+		return nil, notFoundOr500(err, "post")
+	}
+	parentID := ""
+	if req.ParentID != "" {
+		parent, err := h.comments.FindByID(ctx, req.ParentID)
+		if err != nil || parent.PostID != postID {
+			return nil, fmt.Errorf("400: parent comment not found on this post")
+		}
+		// Threads are one level deep; a reply to a reply joins the top-level thread.
+		parentID = parent.ID.Hex()
+		if parent.ParentID != "" {
+			parentID = parent.ParentID
+		}
+	}
+	user, err := h.users.FindByID(ctx, uidHex)
+	if err != nil {
+		return nil, fmt.Errorf("404: User not found")
+	}
+	now := time.Now()
+	comment := models.Comment{PostID: postID, ParentID: parentID, UserID: uidHex, UserName: user.Name, Content: content, CreatedAt: now, UpdatedAt: now}
+	if err := h.addComment(ctx, &comment); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return comment, nil
+}
+
+// addComment stores the comment and counts it on its post. If the count
+// cannot be updated the comment is removed again, so comment_count keeps
+// matching the stored comments and a retry does not leave a duplicate.
+func (h *StoreHandlers) addComment(ctx context.Context, comment *models.Comment) error {
+	if err := h.comments.Create(ctx, comment); err != nil {
+		return err
+	}
+	if err := h.posts.AddComments(ctx, comment.PostID, 1); err != nil {
+		if _, undoErr := h.comments.Delete(ctx, comment.ID.Hex()); undoErr != nil {
+			return errors.Join(err, fmt.Errorf("removing comment %s: %w", comment.ID.Hex(), undoErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// GetComments returns the post's top-level comments, oldest first, each with
+// its replies nested.
+func (h *StoreHandlers) GetComments(ctx *gofr.Context) (interface{}, error) {
+	postID := ctx.PathParam("id")
+	if _, err := h.posts.FindByID(ctx, postID); err != nil {
+		return nil, notFoundOr500(err, "post")
+	}
+	flat, err := h.comments.ListByPost(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return threadComments(flat), nil
+}
+
+func (h *StoreHandlers) UpdateComment(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	comment, err := h.ownComment(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := commentContent(req.Content)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := h.comments.UpdateContent(ctx, comment.ID.Hex(), content, now); err != nil {
+		return nil, notFoundOr500(err, "comment")
+	}
+	comment.Content = content
+	comment.UpdatedAt = now
+	return comment, nil
+}
+
+func (h *StoreHandlers) DeleteComment(ctx *gofr.Context) (interface{}, error) {
+	comment, err := h.ownComment(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := h.comments.Delete(ctx, comment.ID.Hex())
+	if err != nil {
+		return nil, notFoundOr500(err, "comment")
+	}
+	if err := h.posts.AddComments(ctx, comment.PostID, -n); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"message": "Comment deleted", "deleted": n}, nil
+}
+
+// ownComment loads the comment named in the path and checks that the caller
+// is its author.
+func (h *StoreHandlers) ownComment(ctx *gofr.Context) (*models.Comment, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := h.comments.FindByID(ctx, ctx.PathParam("id"))
+	if err != nil {
+		return nil, notFoundOr500(err, "comment")
+	}
+	if comment.UserID != uidHex {
+		return nil, fmt.Errorf("403: not the comment author")
+	}
+	return comment, nil
+}
+
+func commentContent(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("400: content required")
+	}
+	if len([]rune(s)) > maxCommentLength {
+		return "", fmt.Errorf("400: content longer than %d characters", maxCommentLength)
+	}
+	return s, nil
+}
+
+// threadComments nests replies under their top-level comment, keeping order.
+func threadComments(flat []models.Comment) []models.Comment {
+	roots := []models.Comment{}
+	index := map[string]int{}
+	for _, c := range flat {
+		if c.ParentID == "" {
+			index[c.ID.Hex()] = len(roots)
+			roots = append(roots, c)
+		}
+	}
+	for _, c := range flat {
+		if i, ok := index[c.ParentID]; ok {
+			roots[i].Replies = append(roots[i].Replies, c)
+		}
+	}
+	return roots
+}
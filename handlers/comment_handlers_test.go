@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+)
+
+// failingCounts is a post repository whose comment counts cannot be updated.
+type failingCounts struct {
+	store.PostRepository
+}
+
+func (failingCounts) AddComments(context.Context, string, int) error {
+	return errors.New("count unavailable")
+}
+
+func TestAddCommentUndoesOnCountFailure(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewMemoryRepositories()
+	post := models.Post{UserID: "author", CreatedAt: time.Now()}
+	if err := repos.Posts.Create(ctx, &post); err != nil {
+		t.Fatal(err)
+	}
+	postID := post.ID.Hex()
+
+	ok := NewStoreHandlers(repos, nil, nil)
+	if err := ok.addComment(ctx, &models.Comment{PostID: postID, UserID: "u", Content: "first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := repos
+	broken.Posts = failingCounts{repos.Posts}
+	h := NewStoreHandlers(broken, nil, nil)
+	if err := h.addComment(ctx, &models.Comment{PostID: postID, UserID: "u", Content: "second"}); err == nil {
+		t.Fatal("addComment succeeded with the count failing")
+	}
+	comments, err := repos.Comments.ListByPost(ctx, postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := repos.Posts.FindByID(ctx, postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || got.CommentCount != 1 {
+		t.Fatalf("%d comments stored, comment_count %d; want 1 and 1", len(comments), got.CommentCount)
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// Audit actions.
+const (
+	auditDelegationGrant  = "delegation.grant"
+	auditDelegationRevoke = "delegation.revoke"
+	auditDelegationAct    = "delegation.act"
+	auditRequestCreate    = "request.create"
+	auditRequestConfirm   = "request.confirm"
+	auditHistoryView      = "history.view"
+
+	maxAuditEntries = 200
+)
+
+// GrantDelegation lets an elder allow a caregiver account, named by email,
+// to act for them with the given scopes. Granting again replaces the scopes.
+func (h *NeighbourHandlers) GrantDelegation(ctx *gofr.Context) (interface{}, error) {
+	caller, err := ownIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		CaregiverEmail string   `json:"caregiverEmail"`
+		Scopes         []string `json:"scopes"`
+	}
+	if err := ctx.Bind(&body); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	scopes, err := parseScopes(body.Scopes, delegableScopes)
+	if err != nil {
+		return nil, err
+	}
+	caregiver, err := h.users.FindByEmail(ctx, normalizeEmail(body.CaregiverEmail))
+	if err != nil {
+		return nil, notFoundOr500(err, "caregiver")
+	}
+	if caregiver.ID.Hex() == caller.UserID {
+		return nil, fmt.Errorf("400: cannot delegate to yourself")
+	}
+	d := models.Delegation{ElderID: caller.UserID, CaregiverID: caregiver.ID.Hex(), Scopes: scopes, UpdatedAt: time.Now()}
+	if err := h.delegations.Grant(ctx, &d); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	h.audit(ctx, caller.UserID, auditDelegationGrant, d.CaregiverID)
+	return map[string]interface{}{"message": "Delegation granted", "delegation": d}, nil
+}
+
+// RevokeDelegation ends the caller's delegation to the caregiver in the path.
+// Tokens issued under it stop working immediately.
+func (h *NeighbourHandlers) RevokeDelegation(ctx *gofr.Context) (interface{}, error) {
+	caller, err := ownIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	caregiverID := ctx.PathParam("caregiverId")
+	if err := h.delegations.Revoke(ctx, caller.UserID, caregiverID, time.Now()); err != nil {
+		return nil, notFoundOr500(err, "delegation")
+	}
+	h.audit(ctx, caller.UserID, auditDelegationRevoke, caregiverID)
+	return map[string]interface{}{"message": "Delegation revoked"}, nil
+}
+
+// ListDelegations returns the delegations the caller has granted as an elder
+// and received as a caregiver.
+func (h *NeighbourHandlers) ListDelegations(ctx *gofr.Context) (interface{}, error) {
+	caller, err := ownIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	granted, err := h.delegations.ListByElder(ctx, caller.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	received, err := h.delegations.ListByCaregiver(ctx, caller.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"granted": granted, "received": received}, nil
+}
+
+// ActFor exchanges the caregiver's own token for a short-lived token that
+// acts for the elder within the delegated scopes.
+func (h *NeighbourHandlers) ActFor(ctx *gofr.Context) (interface{}, error) {
+	caller, err := ownIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		ElderID string `json:"elderId"`
+	}
+	if err := ctx.Bind(&body); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	d, err := h.delegations.Active(ctx, body.ElderID, caller.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("403: no delegation from this elder")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	tok, err := h.tokens.issueDelegated(caller, d)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	h.audit(ctx, body.ElderID, auditDelegationAct, "")
+	return map[string]interface{}{"token": tok, "elderId": d.ElderID, "scopes": d.Scopes, "expires_in": int(accessTokenTTL.Seconds())}, nil
+}
+
+// GetAuditTrail shows an elder what others have done for them and how their
+// delegations changed.
+func (h *NeighbourHandlers) GetAuditTrail(ctx *gofr.Context) (interface{}, error) {
+	caller, err := ownIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := h.auditLog.ListByElder(ctx, caller.UserID, maxAuditEntries)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"entries": entries}, nil
+}
+
+// audit records an action concerning the elder. The action has already
+// happened, so a failure to record it is logged rather than returned.
+func (h *NeighbourHandlers) audit(ctx *gofr.Context, elderID, action, target string) {
+	caller, _ := identity(ctx)
+	entry := models.AuditEntry{ActorID: caller.UserID, ElderID: elderID, Action: action, Target: target, At: time.Now()}
+	if err := h.auditLog.Record(ctx, &entry); err != nil {
+		ctx.Logger.Errorf("audit %s by %s for %s: %v", action, caller.UserID, elderID, err)
+	}
+}
+
+// ownIdentity returns the caller unless they hold a delegated token or a
+// device key, neither of which may manage or chain delegations.
+func ownIdentity(ctx *gofr.Context) (Identity, error) {
+	id, ok := identity(ctx)
+	if !ok {
+		return Identity{}, fmt.Errorf("401: invalid token")
+	}
+	if id.scoped() {
+		return Identity{}, fmt.Errorf("403: not allowed with a delegated token or device key")
+	}
+	return id, nil
+}
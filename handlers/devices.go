@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"finalapp/models"
+
+	"gofr.dev/pkg/gofr"
+)
+
+const (
+	// deviceKeyHeader carries a device API key in place of a bearer token.
+	deviceKeyHeader = "X-Device-Key"
+	deviceKeyPrefix = "ndk_"
+	// deviceTouchInterval limits last-seen writes from busy devices.
+	deviceTouchInterval = time.Minute
+	maxDeviceNameLen    = 80
+)
+
+// deviceScopes are the permissions a device key can be given.
+var deviceScopes = []Permission{PermCreateRequest, PermViewHistory, PermAudioChat}
+
+// parseDevice authenticates a device API key and records when and where the
+// device was last seen.
+func (t *Tokens) parseDevice(ctx context.Context, key, ip string) (Identity, error) {
+	if !strings.HasPrefix(key, deviceKeyPrefix) {
+		return Identity{}, fmt.Errorf("malformed device key")
+	}
+	d, err := t.devices.FindByKeyHash(ctx, hashToken(key))
+	if err != nil {
+		return Identity{}, fmt.Errorf("unknown device key")
+	}
+	if d.RevokedAt != nil {
+		return Identity{}, fmt.Errorf("device revoked")
+	}
+	now := time.Now()
+	if d.LastSeenAt == nil || now.Sub(*d.LastSeenAt) >= deviceTouchInterval || d.LastSeenIP != ip {
+		// Best effort: a missed update only makes last-seen slightly stale.
+		_ = t.devices.Touch(ctx, d.ID.Hex(), now, ip)
+	}
+	scopes := make([]Permission, len(d.Scopes))
+	for i, s := range d.Scopes {
+		scopes[i] = Permission(s)
+	}
+	return Identity{UserID: "device:" + d.ID.Hex(), DeviceID: d.ID.Hex(), ElderIDs: d.ElderIDs, Scopes: scopes}, nil
+}
+
+type deviceRequest struct {
+	Name     string   `json:"name"`
+	ElderIDs []string `json:"elderIds"`
+	Scopes   []string `json:"scopes"`
+}
+
+// RegisterDevice creates a device bound to one or more elders. The key is
+// only ever shown in this response; it is stored hashed.
+func (h *NeighbourHandlers) RegisterDevice(ctx *gofr.Context) (interface{}, error) {
+	caller, _ := identity(ctx)
+	var body deviceRequest
+	if err := ctx.Bind(&body); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	scopes, err := h.validateDevice(ctx, &body)
+	if err != nil {
+		return nil, err
+	}
+	key, hash, prefix := newDeviceKey()
+	now := time.Now()
+	d := models.Device{
+		Name: body.Name, ElderIDs: body.ElderIDs, Scopes: scopes,
+		KeyHash: hash, KeyPrefix: prefix,
+		CreatedBy: caller.UserID, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := h.devices.Create(ctx, &d); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"message": "Device registered", "device": d, "key": key}, nil
+}
+
+func (h *NeighbourHandlers) ListDevices(ctx *gofr.Context) (interface{}, error) {
+	devices, err := h.devices.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"devices": devices}, nil
+}
+
+// UpdateDevice renames a device or changes its elders and scopes; the key
+// stays the same.
+func (h *NeighbourHandlers) UpdateDevice(ctx *gofr.Context) (interface{}, error) {
+	var body deviceRequest
+	if err := ctx.Bind(&body); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	scopes, err := h.validateDevice(ctx, &body)
+	if err != nil {
+		return nil, err
+	}
+	d, err := h.devices.Update(ctx, ctx.PathParam("id"), body.Name, body.ElderIDs, scopes, time.Now())
+	if err != nil {
+		return nil, notFoundOr500(err, "device")
+	}
+	return map[string]interface{}{"message": "Device updated", "device": d}, nil
+}
+
+// RotateDeviceKey issues a new key for a device; the old key stops working.
+func (h *NeighbourHandlers) RotateDeviceKey(ctx *gofr.Context) (interface{}, error) {
+	id := ctx.PathParam("id")
+	d, err := h.devices.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFoundOr500(err, "device")
+	}
+	if d.RevokedAt != nil {
+		return nil, fmt.Errorf("409: device is revoked")
+	}
+	key, hash, prefix := newDeviceKey()
+	if err := h.devices.SetKey(ctx, id, hash, prefix, time.Now()); err != nil {
+		return nil, notFoundOr500(err, "device")
+	}
+	return map[string]interface{}{"message": "Device key rotated", "key": key, "keyPrefix": prefix}, nil
+}
+
+func (h *NeighbourHandlers) RevokeDevice(ctx *gofr.Context) (interface{}, error) {
+	if err := h.devices.Revoke(ctx, ctx.PathParam("id"), time.Now()); err != nil {
+		return nil, notFoundOr500(err, "device")
+	}
+	return map[string]interface{}{"message": "Device revoked"}, nil
+}
+
+// validateDevice checks the name and that every bound user is an elder, and
+// returns the de-duplicated scopes.
+func (h *NeighbourHandlers) validateDevice(ctx context.Context, body *deviceRequest) ([]string, error) {
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || utf8.RuneCountInString(body.Name) > maxDeviceNameLen {
+		return nil, fmt.Errorf("400: name is required and at most %d characters", maxDeviceNameLen)
+	}
+	if len(body.ElderIDs) == 0 {
+		return nil, fmt.Errorf("400: at least one elderId required")
+	}
+	for _, id := range body.ElderIDs {
+		elder, err := h.users.FindByID(ctx, id)
+		if err != nil {
+			return nil, notFoundOr500(err, "elder "+id)
+		}
+		if elder.Role != models.RoleElder {
+			return nil, fmt.Errorf("400: user %s is not an elder", id)
+		}
+	}
+	return parseScopes(body.Scopes, deviceScopes)
+}
+
+// newDeviceKey returns a fresh key with its hash and a short display prefix.
+func newDeviceKey() (key, hash, prefix string) {
+	key = deviceKeyPrefix + randomToken(32)
+	return key, hashToken(key), key[:len(deviceKeyPrefix)+6]
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// maxFollowing caps how many users one account follows. The following feed
+// reads the posts of every followed user at once, so the cap bounds it. The
+// store enforces it, so concurrent follows cannot pass it.
+const maxFollowing = 2000
+
+// FollowSummary is a user in a follower or following list.
+type FollowSummary struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	ProfilePicture string    `json:"profile_picture"`
+	FollowedAt     time.Time `json:"followed_at"`
+}
+
+// FollowUser makes the caller follow the user in the path. Following someone
+// already followed is not an error.
+func (h *StoreHandlers) FollowUser(ctx *gofr.Context) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target := ctx.PathParam("id")
+	if target == uidHex {
+		return nil, fmt.Errorf("400: you cannot follow yourself")
+	}
+	if _, err := h.users.FindByID(ctx, target); err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	f := models.Follow{FollowerID: uidHex, FolloweeID: target, CreatedAt: time.Now()}
+	if _, err := h.follows.Follow(ctx, &f, maxFollowing); err != nil {
+		if errors.Is(err, store.ErrLimitReached) {
+			return nil, fmt.Errorf("409: you already follow %d users", maxFollowing)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return h.followState(ctx, target, true)
+}
+
+// UnfollowUser stops the caller following the user in the path.
+func (h *StoreHandlers) UnfollowUser(ctx *gofr.Context) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target := ctx.PathParam("id")
+	if _, err := h.follows.Unfollow(ctx, uidHex, target); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return h.followState(ctx, target, false)
+}
+
+func (h *StoreHandlers) followState(ctx *gofr.Context, userID string, following bool) (interface{}, error) {
+	followers, _, err := h.follows.Counts(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"user_id": userID, "following": following, "followers_count": followers}, nil
+}
+
+// GetFollowers lists who follows the user in the path, most recent first.
+func (h *StoreHandlers) GetFollowers(ctx *gofr.Context) (interface{}, error) {
+	return h.followList(ctx, h.follows.Followers, func(f models.Follow) string { return f.FollowerID })
+}
+
+// GetFollowing lists whom the user in the path follows, most recent first.
+func (h *StoreHandlers) GetFollowing(ctx *gofr.Context) (interface{}, error) {
+	return h.followList(ctx, h.follows.Following, func(f models.Follow) string { return f.FolloweeID })
+}
+
+func (h *StoreHandlers) followList(ctx *gofr.Context, list func(context.Context, string, store.FollowQuery) (store.FollowPage, error), other func(models.Follow) string) (interface{}, error) {
+	id := ctx.PathParam("id")
+	if _, err := h.users.FindByID(ctx, id); err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	q := store.FollowQuery{Cursor: ctx.Param("cursor")}
+	if l := ctx.Param("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("400: invalid limit")
+		}
+		q.Limit = n
+	}
+	page, err := list(ctx, id, q)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	ids := make([]string, len(page.Follows))
+	for i, f := range page.Follows {
+		ids[i] = other(f)
+	}
+	users, err := h.users.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	byID := map[string]models.User{}
+	for _, u := range users {
+		byID[u.ID.Hex()] = u
+	}
+	out := []FollowSummary{}
+	for _, f := range page.Follows {
+		if u, ok := byID[other(f)]; ok {
+			out = append(out, FollowSummary{ID: u.ID.Hex(), Name: u.Name, ProfilePicture: u.ProfilePicture, FollowedAt: f.CreatedAt})
+		}
+	}
+	return map[string]interface{}{"users": out, "next_cursor": page.NextCursor}, nil
+}
+
+// GetFollowingFeed is the feed restricted to authors the caller follows. It
+// reads their posts at request time rather than keeping per-reader timelines.
+func (h *StoreHandlers) GetFollowingFeed(ctx *gofr.Context) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q, err := feedParams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q.Authors, err = h.follows.FollowingIDs(ctx, uidHex)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	if len(q.Authors) == 0 {
+		return store.FeedPage{Posts: []models.Post{}}, nil
+	}
+	page, err := h.posts.Feed(ctx, q)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	markLiked(page.Posts, uidHex)
+	return page, nil
+}
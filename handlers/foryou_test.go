@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+)
+
+func TestForYouPageCursor(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewMemoryRepositories()
+	h := NewStoreHandlers(repos, nil, nil)
+	reader := models.User{Email: "r@example.com", PreferredTags: []string{"Ginger"}}
+	if err := repos.Users.Create(ctx, &reader); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+	var preferred string
+	for i := range 7 {
+		p := models.Post{UserID: "author", Section: models.SectionRemedies, CreatedAt: now.Add(-time.Duration(i+1) * time.Hour)}
+		if i == 5 {
+			p.Tags = []string{"#ginger"}
+		}
+		if err := repos.Posts.Create(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+		if i == 5 {
+			preferred = p.ID.Hex()
+		}
+	}
+
+	var got []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor never ran out")
+		}
+		page, err := h.forYouPage(ctx, reader.ID.Hex(), "", cursor, 3, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range page.Posts {
+			got = append(got, p.ID.Hex())
+		}
+		if pages == 0 {
+			// Posted after the first page: later pages keep ranking the
+			// candidates as of the first page, so nothing shifts.
+			late := models.Post{UserID: "author", Section: models.SectionRemedies, Tags: []string{"#ginger"}, CreatedAt: now.Add(time.Second)}
+			if err := repos.Posts.Create(ctx, &late); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(got) != 7 {
+		t.Fatalf("got %d posts, want 7: %v", len(got), got)
+	}
+	seen := map[string]bool{}
+	for _, id := range got {
+		if seen[id] {
+			t.Fatalf("post %s returned twice", id)
+		}
+		seen[id] = true
+	}
+	if got[0] != preferred {
+		t.Fatalf("first post = %s, want the one tagged with the preferred tag %s", got[0], preferred)
+	}
+
+	for _, bad := range []string{"not base64!", "bm90IGpzb24", "eyJhIjoxLCJvIjotMX0"} {
+		if _, err := h.forYouPage(ctx, reader.ID.Hex(), "", bad, 3, now); err == nil || !strings.HasPrefix(err.Error(), "400:") {
+			t.Errorf("cursor %q: err = %v, want 400", bad, err)
+		}
+	}
+}
@@ -1,11 +1,10 @@
 package handlers
 
 import (
-	"fmt"
+	"finalapp/ranking"
 )
 
 type ServerConfig struct {
-	JWTSecret          string
 	ElevenLabsKey      string
 	GeminiKey          string
 	ChatGeminiKey      string
@@ -17,11 +16,19 @@ type ServerConfig struct {
 	CloudAPISecret     string
 	FirestoreProjectID string
 	GoogleCredentials  string
+	// NeighbourDemoMode lets NeighbourSignIn issue demo identities for
+	// unknown emails or when Firestore is not configured. Never enable it
+	// in production.
+	NeighbourDemoMode bool
+	// TrustProxyHeaders takes the client address from X-Forwarded-For.
+	// Only enable it behind a proxy that sets the header.
+	TrustProxyHeaders bool
+	// FeedRanking weighs the for-you feed; unset weights use the defaults.
+	FeedRanking ranking.Weights
 }
 
 var cfg ServerConfig
 
 func SetConfig(c ServerConfig) {
 	cfg = c
-	fmt.Println("handler config initialized")
 }
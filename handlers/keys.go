@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gofr.dev/pkg/gofr"
+	"gofr.dev/pkg/gofr/http/response"
+)
+
+// legacyKeyID names the key built from the single jwt_secret. Tokens signed
+// before key IDs existed carry no kid header and verify against it.
+const legacyKeyID = "default"
+
+// JWTKeyConfig describes one signing key from config.json. HS256 keys take a
+// secret; RS256 and EdDSA keys take a PEM private key file (PKCS#8, or
+// PKCS#1 for RSA).
+type JWTKeyConfig struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret"`
+	PrivateKeyFile string `json:"private_key_file"`
+}
+
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	sign    interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey
+	verify  interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+	private bool        // HMAC secrets are never published
+}
+
+// KeySet holds every key tokens may be verified with and the one new tokens
+// are signed with. To rotate, add a key, make it the signing key and keep the
+// old one listed until tokens signed with it have expired.
+type KeySet struct {
+	keys   map[string]*signingKey
+	order  []string
+	active *signingKey
+}
+
+// LoadKeySet builds the key set from the legacy secret (if any) plus the
+// configured keys. activeID selects the signing key; it defaults to the last
+// configured key, or the legacy secret when there are none.
+func LoadKeySet(legacySecret string, configs []JWTKeyConfig, activeID string) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*signingKey{}}
+	if legacySecret != "" {
+		ks.add(&signingKey{id: legacyKeyID, method: jwt.SigningMethodHS256, sign: []byte(legacySecret), verify: []byte(legacySecret), private: true})
+	}
+	for _, c := range configs {
+		if c.ID == "" {
+			return nil, fmt.Errorf("jwt key without kid")
+		}
+		if _, dup := ks.keys[c.ID]; dup {
+			return nil, fmt.Errorf("duplicate jwt kid %q", c.ID)
+		}
+		k, err := loadKey(c)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", c.ID, err)
+		}
+		ks.add(k)
+		if activeID == "" {
+			ks.active = k
+		}
+	}
+	if activeID != "" {
+		ks.active = ks.keys[activeID]
+		if ks.active == nil {
+			return nil, fmt.Errorf("jwt signing key %q not configured", activeID)
+		}
+	}
+	if ks.active == nil {
+		if len(ks.order) == 0 {
+			return nil, fmt.Errorf("no jwt keys configured")
+		}
+		ks.active = ks.keys[ks.order[0]]
+	}
+	return ks, nil
+}
+
+func (ks *KeySet) add(k *signingKey) {
+	ks.keys[k.id] = k
+	ks.order = append(ks.order, k.id)
+}
+
+func loadKey(c JWTKeyConfig) (*signingKey, error) {
+	k := &signingKey{id: c.ID}
+	switch c.Algorithm {
+	case "HS256", "":
+		if c.Secret == "" {
+			return nil, fmt.Errorf("HS256 key needs a secret")
+		}
+		k.method, k.sign, k.verify, k.private = jwt.SigningMethodHS256, []byte(c.Secret), []byte(c.Secret), true
+		return k, nil
+	case "RS256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", c.Algorithm)
+	}
+	signer, err := readPrivateKey(c.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	switch priv := signer.(type) {
+	case *rsa.PrivateKey:
+		if c.Algorithm != "RS256" {
+			return nil, fmt.Errorf("RSA key configured as %s", c.Algorithm)
+		}
+		k.method, k.sign, k.verify = jwt.SigningMethodRS256, priv, &priv.PublicKey
+	case ed25519.PrivateKey:
+		if c.Algorithm != "EdDSA" {
+			return nil, fmt.Errorf("Ed25519 key configured as %s", c.Algorithm)
+		}
+		k.method, k.sign, k.verify = jwt.SigningMethodEdDSA, priv, priv.Public()
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", signer)
+	}
+	return k, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("%s: key cannot sign", path)
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// sign signs claims with the active key and stamps its kid.
+func (ks *KeySet) sign(claims jwt.MapClaims) (string, error) {
+	t := jwt.NewWithClaims(ks.active.method, claims)
+	t.Header["kid"] = ks.active.id
+	return t.SignedString(ks.active.sign)
+}
+
+// keyFunc picks the verification key by kid and pins the token's algorithm
+// to that key's, so an RS256 public key can never be used as an HMAC secret.
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		kid = legacyKeyID
+	}
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if t.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+	return k.verify, nil
+}
+
+// JWKS publishes the public halves of the asymmetric keys so other services
+// can verify our tokens. HMAC keys are shared secrets and are left out.
+func (ks *KeySet) JWKS(ctx *gofr.Context) (interface{}, error) {
+	keys := []map[string]string{}
+	for _, id := range ks.order {
+		k := ks.keys[id]
+		if k.private {
+			continue
+		}
+		jwk := map[string]string{"kid": k.id, "alg": k.method.Alg(), "use": "sig"}
+		switch pub := k.verify.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = b64(pub.N.Bytes())
+			jwk["e"] = b64(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = b64(pub)
+		}
+		keys = append(keys, jwk)
+	}
+	return response.Raw{Data: map[string]interface{}{"keys": keys}}, nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
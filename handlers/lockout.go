@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"finalapp/mailer"
+	"finalapp/models"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// Sign-in throttling. Failed attempts are counted per account and per client
+// address. Past a few free attempts each further one has to wait twice as
+// long as the last, and enough failures lock the key outright. A locked
+// account is mailed an unlock link; admins can also unlock accounts and
+// addresses.
+
+// Counters registered by main and reported by SignIn.
+const (
+	MetricLoginFailures  = "login_failures_total"
+	MetricLoginThrottled = "login_throttled_total"
+	MetricLoginLockouts  = "login_lockouts_total"
+)
+
+const (
+	maxLoginBackoff = 5 * time.Minute
+	// loginAttemptWindow is how long failures are remembered after the last.
+	loginAttemptWindow = 24 * time.Hour
+	unlockTokenTTL     = 24 * time.Hour
+)
+
+type throttlePolicy struct {
+	scope     string // key prefix and metric label
+	free      int    // failures allowed before backoff starts
+	lockAfter int    // failures that lock the key
+	lockout   time.Duration
+}
+
+var (
+	accountPolicy = throttlePolicy{scope: "account", free: 3, lockAfter: 10, lockout: 30 * time.Minute}
+	// A shared address (an office, a care home) sees many users, so it gets
+	// far more room than a single account.
+	ipPolicy = throttlePolicy{scope: "ip", free: 20, lockAfter: 100, lockout: time.Hour}
+)
+
+func (p throttlePolicy) key(v string) string {
+	return p.scope + ":" + v
+}
+
+// backoff is how long to wait after the given number of failures.
+func (p throttlePolicy) backoff(failures int) time.Duration {
+	n := failures - p.free - 1
+	if n < 0 {
+		return 0
+	}
+	if n > 16 {
+		return maxLoginBackoff
+	}
+	if d := time.Second << n; d < maxLoginBackoff {
+		return d
+	}
+	return maxLoginBackoff
+}
+
+// retryAt returns when the key may try again; the zero time means now.
+func (p throttlePolicy) retryAt(a *models.LoginAttempt, now time.Time) time.Time {
+	if a.LockedUntil != nil && a.LockedUntil.After(now) {
+		return *a.LockedUntil
+	}
+	if d := p.backoff(a.Failures); d > 0 {
+		return a.LastFailure.Add(d)
+	}
+	return time.Time{}
+}
+
+type throttleKey struct {
+	policy throttlePolicy
+	key    string
+}
+
+type lockedError struct {
+	retryAt time.Time
+}
+
+func (e *lockedError) Error() string {
+	wait := time.Until(e.retryAt).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return fmt.Sprintf("too many failed sign-in attempts, try again in %s", wait)
+}
+
+// SignIn is Authenticate behind the sign-in throttle: callers that are
+// backing off or locked out get a *lockedError without their password being
+// checked, and failures count towards the next lockout.
+func (a *Accounts) SignIn(ctx *gofr.Context, email, password string) (*models.User, error) {
+	email = normalizeEmail(email)
+	keys, err := a.checkThrottle(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.Authenticate(ctx, email, password)
+	if errors.Is(err, errInvalidCredentials) {
+		a.recordFailure(ctx, email, keys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// With two factors the count is only cleared once the second one
+	// passes, so a known password cannot be used to reset code guessing.
+	if !twoFactorEnabled(user) {
+		a.clearFailures(ctx, email)
+	}
+	return user, nil
+}
+
+// checkThrottle returns the throttle keys for a sign-in to email from this
+// client, or a *lockedError if any of them must still wait.
+func (a *Accounts) checkThrottle(ctx *gofr.Context, email string) ([]throttleKey, error) {
+	keys := []throttleKey{{accountPolicy, accountPolicy.key(email)}}
+	if ip := clientIP(ctx); ip != "" {
+		keys = append(keys, throttleKey{ipPolicy, ipPolicy.key(ip)})
+	}
+	now := time.Now()
+	for _, k := range keys {
+		rec, err := a.attempts.Find(ctx, k.key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if at := k.policy.retryAt(rec, now); at.After(now) {
+			ctx.Metrics().IncrementCounter(ctx, MetricLoginThrottled, "scope", k.policy.scope)
+			return nil, &lockedError{retryAt: at}
+		}
+	}
+	return keys, nil
+}
+
+// clearFailures forgets the account's failed sign-ins. The address keeps
+// its count: one good sign-in must not wipe out failures against other
+// accounts.
+func (a *Accounts) clearFailures(ctx *gofr.Context, email string) {
+	if err := a.attempts.Clear(ctx, accountPolicy.key(email)); err != nil {
+		ctx.Logger.Errorf("clearing failed sign-ins for %s: %v", email, err)
+	}
+}
+
+func (a *Accounts) recordFailure(ctx *gofr.Context, email string, keys []throttleKey) {
+	ctx.Metrics().IncrementCounter(ctx, MetricLoginFailures)
+	now := time.Now()
+	for _, k := range keys {
+		rec, err := a.attempts.RecordFailure(ctx, k.key, now, loginAttemptWindow)
+		if err != nil {
+			ctx.Logger.Errorf("recording failed sign-in for %s: %v", k.key, err)
+			continue
+		}
+		if rec.Failures < k.policy.lockAfter {
+			continue
+		}
+		until := now.Add(k.policy.lockout)
+		if err := a.attempts.Lock(ctx, k.key, until, loginAttemptWindow); err != nil {
+			ctx.Logger.Errorf("locking %s: %v", k.key, err)
+			continue
+		}
+		ctx.Metrics().IncrementCounter(ctx, MetricLoginLockouts, "scope", k.policy.scope)
+		ctx.Logger.Warnf("sign-in locked for %s until %s", k.key, until.Format(time.RFC3339))
+		if k.policy.scope == accountPolicy.scope {
+			if err := a.sendUnlock(ctx, email); err != nil {
+				ctx.Logger.Errorf("sending unlock link to %s: %v", email, err)
+			}
+		}
+	}
+}
+
+// sendUnlock mails the account owner a link that lifts the lockout early.
+// Unknown addresses are ignored.
+func (a *Accounts) sendUnlock(ctx context.Context, email string) error {
+	user, err := a.users.FindByEmail(ctx, email)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tok, err := a.issueAccountToken(ctx, user.ID.Hex(), models.PurposeUnlockAccount, unlockTokenTTL)
+	if err != nil {
+		return err
+	}
+	return a.mail.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account has been locked",
+		Body: fmt.Sprintf("Hi %s,\n\nThere were too many failed attempts to sign in to your account, so sign-in is paused for %s. If that was you, open this link to unlock it now:\n\n%s/?unlock_token=%s\n\nIf it wasn't you, consider resetting your password.",
+			user.Name, accountPolicy.lockout, a.baseURL, tok),
+	})
+}
+
+// ConfirmUnlock lifts an account lockout using the link from sendUnlock.
+func (a *Accounts) ConfirmUnlock(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	t, err := a.consume(ctx, req.Token, models.PurposeUnlockAccount)
+	if err != nil {
+		if errors.Is(err, errInvalidAccountToken) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	user, err := a.users.FindByID(ctx, t.UserID)
+	if err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	if err := a.attempts.Clear(ctx, accountPolicy.key(user.Email)); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"message": "Account unlocked"}, nil
+}
+
+// Unlock lets an admin clear the failed sign-ins and lockout of an account,
+// a client address, or both.
+func (a *Accounts) Unlock(ctx *gofr.Context) (interface{}, error) {
+	var body struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+	if err := ctx.Bind(&body); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	var keys []string
+	if email := normalizeEmail(body.Email); email != "" {
+		keys = append(keys, accountPolicy.key(email))
+	}
+	if ip := strings.TrimSpace(body.IP); ip != "" {
+		keys = append(keys, ipPolicy.key(ip))
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("400: email or ip required")
+	}
+	for _, k := range keys {
+		if err := a.attempts.Clear(ctx, k); err != nil {
+			return nil, fmt.Errorf("500: %v", err)
+		}
+	}
+	return map[string]interface{}{"message": "Unlocked", "keys": keys}, nil
+}
+
+type clientIPKey struct{}
+
+// ClientIP stores the caller's address on the request context for sign-in
+// throttling. X-Forwarded-For can be set by anyone, so it is only used when
+// cfg.TrustProxyHeaders says a proxy in front of us sets it; the last entry
+// is the one that proxy appended.
+func ClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" && cfg.TrustProxyHeaders {
+			parts := strings.Split(fwd, ",")
+			ip = strings.TrimSpace(parts[len(parts)-1])
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+	})
+}
+
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
@@ -2,23 +2,27 @@ package handlers
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
 	"os"
 	"strconv"
 	"time"
 
+	"finalapp/models"
+	"finalapp/store"
+
 	"cloud.google.com/go/firestore"
-	"github.com/golang-jwt/jwt/v5"
 	"gofr.dev/pkg/gofr"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type NUser struct {
 	ID        string    `json:"id" firestore:"id"`
+	AccountID string    `json:"accountId" firestore:"accountId"` // Linked models.User ID
 	Name      string    `json:"name" firestore:"name"`
 	Email     string    `json:"email" firestore:"email"`
 	Password  string    `json:"password" firestore:"password"`
@@ -29,6 +33,25 @@ type NUser struct {
 	CreatedAt time.Time `json:"createdAt" firestore:"createdAt"`
 }
 
+// NRequest is the part of a Firestore help request that access checks and
+// state transitions need.
+type NRequest struct {
+	ID       string `json:"id" firestore:"id"`
+	ElderID  string `json:"elderId" firestore:"elderId"`
+	HelperID string `json:"helperId" firestore:"helperId"`
+	Status   string `json:"status" firestore:"status"`
+}
+
+// Help request lifecycle: pending -> assigned -> completed -> rewarded.
+const (
+	requestPending   = "pending"
+	requestAssigned  = "assigned"
+	requestCompleted = "completed"
+	requestRewarded  = "rewarded"
+
+	rewardPerRequest = 10
+)
+
 type NLocation struct {
 	Lat, Lng float64 `json:"lat" firestore:"lat"`
 }
@@ -48,7 +71,30 @@ func initFirestore() *firestore.Client {
 	return cl
 }
 
-func NeighbourSignUp(ctx *gofr.Context) (interface{}, error) {
+// NeighbourHandlers serves the Neighbour helper network API. Sign-up and
+// sign-in go through the shared Accounts service, so a Neighbour user is also
+// a community user.
+type NeighbourHandlers struct {
+	accounts    *Accounts
+	tokens      *Tokens
+	users       store.UserRepository
+	delegations store.DelegationRepository
+	auditLog    store.AuditRepository
+	devices     store.DeviceRepository
+}
+
+func NewNeighbourHandlers(repos store.Repositories, accounts *Accounts, tokens *Tokens) *NeighbourHandlers {
+	return &NeighbourHandlers{
+		accounts:    accounts,
+		tokens:      tokens,
+		users:       repos.Users,
+		delegations: repos.Delegations,
+		auditLog:    repos.Audit,
+		devices:     repos.Devices,
+	}
+}
+
+func (h *NeighbourHandlers) NeighbourSignUp(ctx *gofr.Context) (interface{}, error) {
 	var user NUser
 	if err := ctx.Bind(&user); err != nil {
 		return nil, fmt.Errorf("invalid request body: %v", err)
@@ -56,59 +102,164 @@ func NeighbourSignUp(ctx *gofr.Context) (interface{}, error) {
 	if user.Name == "" || user.Email == "" || user.Password == "" || user.Role == "" {
 		return nil, fmt.Errorf("missing required fields")
 	}
-	client := initFirestore()
-	// Hash password
-	hasher := sha256.New()
-	hasher.Write([]byte(user.Password))
-	user.Password = hex.EncodeToString(hasher.Sum(nil))
-	user.ID = user.Email
-	user.CreatedAt = time.Now()
-	user.Reward = 0
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": user.ID, "email": user.Email, "role": user.Role, "exp": time.Now().Add(24 * time.Hour).Unix()})
-	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+	if !models.ValidNeighbourRole(user.Role) {
+		return nil, fmt.Errorf("400: role must be %q or %q", models.RoleElder, models.RoleHelper)
+	}
+	account, err := h.accounts.Register(ctx, user.Name, user.Email, user.Password, user.Role)
+	if err != nil {
+		if errors.Is(err, errInvalidEmail) || errors.Is(err, errWeakPassword) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		if errors.Is(err, errAccountExists) {
+			return nil, fmt.Errorf("409: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	if !account.EmailVerified {
+		if err := h.accounts.sendVerification(ctx, account); err != nil {
+			ctx.Logger.Errorf("sending verification to %s: %v", account.Email, err)
+		}
+	}
+	user = saveNeighbourProfile(account, user)
+	pair, err := h.tokens.issue(ctx, account)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %v", err)
 	}
-	if client != nil {
-		_, _ = client.Collection("users").Doc(user.ID).Set(context.Background(), user)
+	return map[string]interface{}{"message": "User created", "user": user, "token": pair.AccessToken, "refresh_token": pair.RefreshToken}, nil
+}
+
+// NeighbourJoin gives a signed-in account that signed up for the community
+// feed an elder or helper role. Sign-up refuses emails that already have an
+// account, so this is the only way an existing account joins, and it is only
+// reachable after sign-in (and its second step) has succeeded. The new role
+// reaches the access token on the next refresh.
+func (h *NeighbourHandlers) NeighbourJoin(ctx *gofr.Context) (interface{}, error) {
+	var body struct {
+		Role     string    `json:"role"`
+		Location NLocation `json:"location"`
+	}
+	if err := ctx.Bind(&body); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	if !models.ValidNeighbourRole(body.Role) {
+		return nil, fmt.Errorf("400: role must be %q or %q", models.RoleElder, models.RoleHelper)
+	}
+	caller, ok := identity(ctx)
+	if !ok {
+		return nil, fmt.Errorf("401: invalid token")
 	}
+	if caller.scoped() {
+		return nil, fmt.Errorf("403: only the account owner can join")
+	}
+	account, err := h.users.FindByID(ctx, caller.UserID)
+	if err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	if err := h.accounts.JoinNeighbour(ctx, account, body.Role); err != nil {
+		if errors.Is(err, errAlreadyJoined) {
+			return nil, fmt.Errorf("409: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	user := saveNeighbourProfile(account, NUser{Name: account.Name, Role: body.Role, Location: body.Location})
+	return map[string]interface{}{"message": "Joined the Neighbour network; refresh your session to use the new role", "user": user}, nil
+}
+
+// saveNeighbourProfile writes the Firestore profile of a newly linked
+// account. The password lives on the account; the profile only links to it.
+func saveNeighbourProfile(account *models.User, user NUser) NUser {
 	user.Password = ""
-	return map[string]interface{}{"message": "User created", "user": user, "token": tokenString}, nil
+	user.ID = account.NeighbourID
+	user.Email = account.Email
+	user.AccountID = account.ID.Hex()
+	user.CreatedAt = time.Now()
+	user.Reward = 0
+	if client := initFirestore(); client != nil {
+		defer client.Close()
+		_, _ = client.Collection("users").Doc(user.ID).Set(context.Background(), user)
+	}
+	return user
 }
 
-func NeighbourSignIn(ctx *gofr.Context) (interface{}, error) {
+func (h *NeighbourHandlers) NeighbourSignIn(ctx *gofr.Context) (interface{}, error) {
 	var cred struct{ Email, Password string }
 	if err := ctx.Bind(&cred); err != nil {
 		return nil, fmt.Errorf("invalid request body: %v", err)
 	}
-	client := initFirestore()
-	user := NUser{ID: cred.Email, Name: "Demo User", Email: cred.Email, Role: "elder", CreatedAt: time.Now(), Reward: 0}
-	if client != nil {
-		if doc, err := client.Collection("users").Doc(user.ID).Get(context.Background()); err == nil {
+	if cred.Email == "" || cred.Password == "" {
+		return nil, fmt.Errorf("400: email/password required")
+	}
+	account, err := h.accounts.SignIn(ctx, cred.Email, cred.Password)
+	if errors.Is(err, errInvalidCredentials) && cfg.NeighbourDemoMode {
+		if _, lookupErr := h.accounts.users.FindByEmail(ctx, normalizeEmail(cred.Email)); errors.Is(lookupErr, store.ErrNotFound) {
+			return h.demoSignIn(cred.Email)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			return nil, fmt.Errorf("401: %v", err)
+		}
+		var locked *lockedError
+		if errors.As(err, &locked) {
+			return nil, fmt.Errorf("429: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	challenge, err := h.tokens.secondStep(account)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+	user := neighbourProfile(ctx, account)
+	pair, err := h.tokens.issue(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+	return map[string]interface{}{"message": "Sign in successful", "user": user, "token": pair.AccessToken, "refresh_token": pair.RefreshToken}, nil
+}
+
+// neighbourProfile loads the account's Firestore profile, falling back to
+// the account fields when there is none.
+func neighbourProfile(ctx context.Context, account *models.User) NUser {
+	user := NUser{ID: account.NeighbourID, AccountID: account.ID.Hex(), Name: account.Name, Email: account.Email, Role: account.Role, CreatedAt: account.CreatedAt}
+	if client := initFirestore(); client != nil && account.NeighbourID != "" {
+		defer client.Close()
+		if doc, err := client.Collection("users").Doc(account.NeighbourID).Get(ctx); err == nil {
 			_ = doc.DataTo(&user)
 		}
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": user.ID, "email": user.Email, "role": user.Role, "exp": time.Now().Add(24 * time.Hour).Unix()})
-	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+	user.AccountID = account.ID.Hex()
+	user.Role = account.Role
+	user.Password = ""
+	return user
+}
+
+// demoSignIn issues a demo identity for an unknown email. Only reachable
+// when NeighbourDemoMode is enabled. Demo sessions cannot be refreshed.
+func (h *NeighbourHandlers) demoSignIn(email string) (interface{}, error) {
+	user := NUser{ID: "demo:" + email, Name: "Demo User", Email: email, Role: models.RoleElder, CreatedAt: time.Now(), Reward: 0}
+	tokenString, err := h.tokens.issueAccess(Identity{UserID: user.ID, Email: user.Email, Role: user.Role})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %v", err)
 	}
 	return map[string]interface{}{"message": "Sign in successful", "user": user, "token": tokenString}, nil
 }
 
-func NeighbourUploadAudio(ctx *gofr.Context) (interface{}, error) {
+func (h *NeighbourHandlers) NeighbourUploadAudio(ctx *gofr.Context) (interface{}, error) {
+	elderID, err := h.elderFor(ctx)
+	if err != nil {
+		return nil, err
+	}
 	elderLatStr := ctx.Param("elderLat")
 	elderLngStr := ctx.Param("elderLng")
-	elderID := ctx.Param("elderId")
 	if elderLatStr == "" {
 		elderLatStr = "40.7128"
 	}
 	if elderLngStr == "" {
 		elderLngStr = "-74.0060"
 	}
-	if elderID == "" {
-		elderID = "demo-elder"
-	}
 	elderLat, _ := strconv.ParseFloat(elderLatStr, 64)
 	elderLng, _ := strconv.ParseFloat(elderLngStr, 64)
 	requestID := fmt.Sprintf("%s-%d", elderID, time.Now().Unix())
@@ -120,11 +271,14 @@ func NeighbourUploadAudio(ctx *gofr.Context) (interface{}, error) {
 		_, _ = client.Collection("requests").Doc(requestID).Set(context.Background(), map[string]interface{}{
 			"id": requestID, "title": title, "audioUrl": audioURL, "transcription": transcription,
 			"elderId": elderID, "elderLocation": map[string]float64{"lat": elderLat, "lng": elderLng},
-			"status": "pending", "createdAt": time.Now(),
+			"status": requestPending, "createdAt": time.Now(),
 		})
 		// basic nearby helper scan
 		_, _ = getNearbyHelpers(client, elderLat, elderLng)
 	}
+	if caller, _ := identity(ctx); caller.UserID != elderID {
+		h.audit(ctx, elderID, auditRequestCreate, requestID)
+	}
 	return map[string]interface{}{"message": "Audio uploaded and request created", "requestId": requestID, "url": audioURL, "transcription": transcription, "title": title}, nil
 }
 
@@ -167,7 +321,7 @@ func distanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
 	return R * c
 }
 
-func NeighbourGetHelperRequests(ctx *gofr.Context) (interface{}, error) {
+func (h *NeighbourHandlers) NeighbourGetHelperRequests(ctx *gofr.Context) (interface{}, error) {
 	userID := ctx.Param("userName")
 	if userID == "" {
 		userID = "demo-helper"
@@ -179,26 +333,274 @@ func NeighbourGetHelperRequests(ctx *gofr.Context) (interface{}, error) {
 	return map[string]interface{}{"requests": requests}, nil
 }
 
-func NeighbourAssignRequest(ctx *gofr.Context) (interface{}, error) {
+func (h *NeighbourHandlers) NeighbourAssignRequest(ctx *gofr.Context) (interface{}, error) {
 	var body struct{ RequestID, HelperID string }
 	if err := ctx.Bind(&body); err != nil {
 		return nil, fmt.Errorf("invalid request body: %v", err)
 	}
+	caller, _ := identity(ctx)
+	// Helpers can only take requests for themselves.
+	if body.HelperID == "" || caller.Role == models.RoleHelper {
+		if body.HelperID != "" && body.HelperID != caller.UserID {
+			return nil, fmt.Errorf("403: helpers can only assign requests to themselves")
+		}
+		body.HelperID = caller.UserID
+	}
+	if demo, ok := demoRequestResponse("Request assigned successfully"); ok {
+		return demo, nil
+	}
+	if err := h.checkHelper(ctx, body.HelperID); err != nil {
+		return nil, err
+	}
+	err := updateRequest(ctx, body.RequestID, func(_ *firestore.Client, _ *firestore.Transaction, r NRequest) ([]firestore.Update, error) {
+		if r.Status != requestPending {
+			return nil, fmt.Errorf("409: request is %s, not %s", r.Status, requestPending)
+		}
+		return []firestore.Update{{Path: "helperId", Value: body.HelperID}, {Path: "status", Value: requestAssigned}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{"message": "Request assigned successfully"}, nil
 }
 
-func NeighbourConfirmRequest(ctx *gofr.Context) (interface{}, error) {
+func (h *NeighbourHandlers) NeighbourConfirmRequest(ctx *gofr.Context) (interface{}, error) {
 	var body struct{ RequestID string }
 	if err := ctx.Bind(&body); err != nil {
 		return nil, fmt.Errorf("invalid request body: %v", err)
 	}
+	caller, _ := identity(ctx)
+	if demo, ok := demoRequestResponse("Request confirmed successfully"); ok {
+		return demo, nil
+	}
+	var elderID string
+	err := updateRequest(ctx, body.RequestID, func(_ *firestore.Client, _ *firestore.Transaction, r NRequest) ([]firestore.Update, error) {
+		if r.ElderID != caller.Subject() && caller.Role != models.RoleAdmin {
+			return nil, fmt.Errorf("403: only the elder who made the request can confirm it")
+		}
+		if r.Status != requestAssigned {
+			return nil, fmt.Errorf("409: request is %s, not %s", r.Status, requestAssigned)
+		}
+		elderID = r.ElderID
+		return []firestore.Update{{Path: "status", Value: requestCompleted}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if caller.UserID != elderID {
+		h.audit(ctx, elderID, auditRequestConfirm, body.RequestID)
+	}
 	return map[string]interface{}{"message": "Request confirmed successfully"}, nil
 }
 
-func NeighbourClaimReward(ctx *gofr.Context) (interface{}, error) {
+func (h *NeighbourHandlers) NeighbourClaimReward(ctx *gofr.Context) (interface{}, error) {
 	var body struct{ HelperID, RequestID string }
 	if err := ctx.Bind(&body); err != nil {
 		return nil, fmt.Errorf("invalid request body: %v", err)
 	}
-	return map[string]interface{}{"message": "Reward claimed successfully", "newBalance": 10}, nil
+	caller, _ := identity(ctx)
+	if demo, ok := demoRequestResponse("Reward claimed successfully"); ok {
+		demo["newBalance"] = rewardPerRequest
+		return demo, nil
+	}
+	// The reward is credited in the transaction that marks the request
+	// rewarded, so a request can neither pay twice nor be marked paid
+	// without paying.
+	var balance int
+	err := updateRequest(ctx, body.RequestID, func(client *firestore.Client, tx *firestore.Transaction, r NRequest) ([]firestore.Update, error) {
+		if r.HelperID != caller.UserID {
+			return nil, fmt.Errorf("403: only the assigned helper can claim this reward")
+		}
+		if r.Status != requestCompleted {
+			return nil, fmt.Errorf("409: request is %s, not %s", r.Status, requestCompleted)
+		}
+		var err error
+		if balance, err = h.addReward(ctx, client, tx, r.HelperID, rewardPerRequest); err != nil {
+			return nil, fmt.Errorf("500: %v", err)
+		}
+		return []firestore.Update{{Path: "status", Value: requestRewarded}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"message": "Reward claimed successfully", "newBalance": balance}, nil
+}
+
+// NeighbourRequestHistory lists an elder's help requests, newest first.
+// The elder is chosen as for NeighbourUploadAudio.
+func (h *NeighbourHandlers) NeighbourRequestHistory(ctx *gofr.Context) (interface{}, error) {
+	caller, _ := identity(ctx)
+	elderID, err := h.elderFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client := initFirestore()
+	if client == nil {
+		if cfg.NeighbourDemoMode {
+			return map[string]interface{}{"requests": []map[string]interface{}{}}, nil
+		}
+		return nil, fmt.Errorf("503: request store not configured")
+	}
+	defer client.Close()
+	iter := client.Collection("requests").Where("elderId", "==", elderID).OrderBy("createdAt", firestore.Desc).Documents(ctx)
+	defer iter.Stop()
+	requests := []map[string]interface{}{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("500: %v", err)
+		}
+		requests = append(requests, doc.Data())
+	}
+	if caller.UserID != elderID {
+		h.audit(ctx, elderID, auditHistoryView, "")
+	}
+	return map[string]interface{}{"requests": requests}, nil
+}
+
+// elderFor resolves the elder a Neighbour request concerns. Elders act for
+// themselves and caregivers for the elder their delegated token names.
+// Devices pick one of their bound elders with ?elderId=, which may be
+// omitted when there is only one; coordinators and admins may name any
+// elder.
+func (h *NeighbourHandlers) elderFor(ctx *gofr.Context) (string, error) {
+	caller, _ := identity(ctx)
+	if caller.OnBehalfOf != "" || caller.Role == models.RoleElder {
+		return caller.Subject(), nil
+	}
+	elderID := ctx.Param("elderId")
+	if caller.DeviceID != "" {
+		if elderID == "" && len(caller.ElderIDs) == 1 {
+			return caller.ElderIDs[0], nil
+		}
+		for _, id := range caller.ElderIDs {
+			if id == elderID {
+				return elderID, nil
+			}
+		}
+		if elderID == "" {
+			return "", fmt.Errorf("400: elderId required")
+		}
+		return "", fmt.Errorf("403: device is not registered for elder %s", elderID)
+	}
+	if elderID == "" {
+		return "", fmt.Errorf("400: elderId required")
+	}
+	elder, err := h.users.FindByID(ctx, elderID)
+	if err != nil {
+		return "", notFoundOr500(err, "elder")
+	}
+	if elder.Role != models.RoleElder {
+		return "", fmt.Errorf("400: user %s is not an elder", elderID)
+	}
+	return elderID, nil
+}
+
+// checkHelper refuses to assign a request to anyone but a helper.
+func (h *NeighbourHandlers) checkHelper(ctx context.Context, helperID string) error {
+	helper, err := h.users.FindByID(ctx, helperID)
+	if err != nil {
+		return notFoundOr500(err, "helper")
+	}
+	if helper.Role != models.RoleHelper {
+		return fmt.Errorf("400: user %s is not a helper", helperID)
+	}
+	return nil
+}
+
+// updateRequest loads a help request in a transaction and applies the updates
+// returned by check, which rejects the transition by returning an error.
+// check may read and write other documents through tx; Firestore requires
+// its reads to come before its writes.
+func updateRequest(ctx context.Context, requestID string, check func(*firestore.Client, *firestore.Transaction, NRequest) ([]firestore.Update, error)) error {
+	client := initFirestore()
+	if client == nil {
+		return fmt.Errorf("503: request store not configured")
+	}
+	defer client.Close()
+	ref := client.Collection("requests").Doc(requestID)
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("404: request not found")
+		}
+		if err != nil {
+			return fmt.Errorf("500: %v", err)
+		}
+		var r NRequest
+		if err := doc.DataTo(&r); err != nil {
+			return fmt.Errorf("500: %v", err)
+		}
+		updates, err := check(client, tx, r)
+		if err != nil {
+			return err
+		}
+		return tx.Update(ref, updates)
+	})
+}
+
+// addReward credits the helper's Firestore profile within tx and returns the
+// new balance.
+func (h *NeighbourHandlers) addReward(ctx context.Context, client *firestore.Client, tx *firestore.Transaction, helperID string, amount int) (int, error) {
+	account, err := h.users.FindByID(ctx, helperID)
+	if err != nil {
+		return 0, err
+	}
+	ref := client.Collection("users").Doc(account.NeighbourID)
+	doc, err := tx.Get(ref)
+	if err != nil {
+		return 0, err
+	}
+	var nu NUser
+	if err := doc.DataTo(&nu); err != nil {
+		return 0, err
+	}
+	balance := nu.Reward + amount
+	if err := tx.Update(ref, []firestore.Update{{Path: "reward", Value: balance}}); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// relinkRequests rewrites the help requests that name a Neighbour user by
+// their Firestore profile ID, as requests made before accounts existed do,
+// to name the account ID that elderFor and the request handlers compare.
+func relinkRequests(ctx context.Context, client *firestore.Client, docID, accountID string) error {
+	if docID == accountID {
+		return nil
+	}
+	for _, field := range []string{"elderId", "helperId"} {
+		iter := client.Collection("requests").Where(field, "==", docID).Documents(ctx)
+		err := func() error {
+			defer iter.Stop()
+			for {
+				doc, err := iter.Next()
+				if err == iterator.Done {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: field, Value: accountID}}); err != nil {
+					return err
+				}
+			}
+		}()
+		if err != nil {
+			return fmt.Errorf("relinking requests by %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// demoRequestResponse returns the canned success response used when
+// Firestore is not configured and demo mode is on.
+func demoRequestResponse(message string) (map[string]interface{}, bool) {
+	if cfg.FirestoreProjectID != "" || !cfg.NeighbourDemoMode {
+		return nil, false
+	}
+	return map[string]interface{}{"message": message}, true
 }
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"finalapp/models"
+)
+
+func TestCheckHelper(t *testing.T) {
+	ctx := context.Background()
+	a, repos := newTestAccounts()
+	h := NewNeighbourHandlers(repos, a, nil)
+	helper, err := a.Register(ctx, "Hal", "hal@example.com", "correct horse", models.RoleHelper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elder, err := a.Register(ctx, "Eve", "eve@example.com", "correct horse", models.RoleElder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.checkHelper(ctx, helper.ID.Hex()); err != nil {
+		t.Fatalf("helper refused: %v", err)
+	}
+	for id, status := range map[string]string{elder.ID.Hex(): "400:", "64b7f0c2a1b2c3d4e5f60718": "404:"} {
+		if err := h.checkHelper(ctx, id); err == nil || !strings.HasPrefix(err.Error(), status) {
+			t.Errorf("checkHelper(%s) = %v, want %s", id, err, status)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"finalapp/models"
+	"finalapp/oidc"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+)
+
+const oidcSessionTTL = 10 * time.Minute
+
+// OIDCLogin signs users in through external OpenID Connect providers, then
+// issues our own tokens exactly as Login does. The provider sends the
+// browser back to the web app, which posts the code and state to Callback.
+type OIDCLogin struct {
+	providers map[string]*oidc.Provider
+	order     []string
+	accounts  *Accounts
+	tokens    *Tokens
+	sessions  store.OIDCSessionRepository
+}
+
+func NewOIDCLogin(repos store.Repositories, accounts *Accounts, tokens *Tokens, providers []*oidc.Provider) *OIDCLogin {
+	o := &OIDCLogin{providers: map[string]*oidc.Provider{}, accounts: accounts, tokens: tokens, sessions: repos.OIDCSessions}
+	for _, p := range providers {
+		o.providers[p.Name()] = p
+		o.order = append(o.order, p.Name())
+	}
+	return o
+}
+
+// Providers lists the configured providers for the sign-in form.
+func (o *OIDCLogin) Providers(ctx *gofr.Context) (interface{}, error) {
+	list := []map[string]string{}
+	for _, name := range o.order {
+		list = append(list, map[string]string{"name": name, "display_name": o.providers[name].DisplayName()})
+	}
+	return map[string]interface{}{"providers": list}, nil
+}
+
+// Start begins a sign-in with the provider in the path and returns the URL
+// to send the browser to. The web app keeps the state and only completes a
+// callback carrying the same one, so a forged callback link cannot sign the
+// browser into someone else's account.
+func (o *OIDCLogin) Start(ctx *gofr.Context) (interface{}, error) {
+	p, ok := o.providers[ctx.PathParam("provider")]
+	if !ok {
+		return nil, fmt.Errorf("404: unknown provider")
+	}
+	state, nonce, verifier := oidc.RandomString(), oidc.RandomString(), oidc.RandomString()
+	session := models.OIDCSession{StateHash: hashToken(state), Provider: p.Name(), Nonce: nonce, Verifier: verifier, ExpiresAt: time.Now().Add(oidcSessionTTL)}
+	if err := o.sessions.Create(ctx, &session); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	url, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		ctx.Logger.Errorf("oidc provider %s: %v", p.Name(), err)
+		return nil, fmt.Errorf("503: sign-in provider unavailable")
+	}
+	return map[string]interface{}{"url": url, "state": state}, nil
+}
+
+// Callback finishes the sign-in with the code and state the provider sent
+// back, linking or creating the account by verified email.
+func (o *OIDCLogin) Callback(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		State string `json:"state"`
+		Code  string `json:"code"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	if req.State == "" || req.Code == "" {
+		return nil, fmt.Errorf("400: state and code required")
+	}
+	p, claims, err := o.redeem(ctx, req.State, req.Code)
+	if err != nil {
+		if p == nil {
+			return nil, err
+		}
+		ctx.Logger.Errorf("oidc provider %s: %v", p.Name(), err)
+		return nil, fmt.Errorf("401: sign-in with %s failed", p.DisplayName())
+	}
+	user, err := o.accounts.SignInExternal(ctx, claims)
+	if err != nil {
+		if errors.Is(err, errEmailNotVerified) || errors.Is(err, errInvalidEmail) {
+			return nil, fmt.Errorf("403: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	// The provider's sign-in does not stand in for our second factor.
+	challenge, err := o.tokens.secondStep(user)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+	pair, err := o.tokens.issue(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"user_id": user.ID.Hex(), "token": pair.AccessToken, "refresh_token": pair.RefreshToken, "expires_in": pair.ExpiresIn}, nil
+}
+
+// redeem consumes the sign-in attempt that state names and exchanges code
+// with its provider. A state that was not issued, has expired or was already
+// used matches no attempt. When the provider rejects the code, the provider
+// is returned with the error so the caller can report it.
+func (o *OIDCLogin) redeem(ctx context.Context, state, code string) (*oidc.Provider, *oidc.Claims, error) {
+	session, err := o.sessions.Consume(ctx, hashToken(state), time.Now())
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil, fmt.Errorf("400: sign-in attempt expired, please try again")
+		}
+		return nil, nil, fmt.Errorf("500: %v", err)
+	}
+	p, ok := o.providers[session.Provider]
+	if !ok {
+		return nil, nil, fmt.Errorf("400: unknown provider")
+	}
+	claims, err := p.Exchange(ctx, code, session.Verifier, session.Nonce)
+	if err != nil {
+		return p, nil, err
+	}
+	return p, claims, nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"finalapp/models"
+	"finalapp/oidc"
+	"finalapp/store"
+)
+
+func TestOIDCRedeemState(t *testing.T) {
+	ctx := context.Background()
+	// The provider is unreachable, so redeem returning it shows the state
+	// was accepted and the code went on to the exchange.
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+	p, err := oidc.New(oidc.Config{Name: "test", Issuer: down.URL, ClientID: "client", RedirectURL: "http://app/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := store.NewMemoryRepositories()
+	o := NewOIDCLogin(repos, nil, nil, []*oidc.Provider{p})
+	for state, ttl := range map[string]time.Duration{"right": time.Minute, "stale": -time.Minute} {
+		session := models.OIDCSession{StateHash: hashToken(state), Provider: "test", Nonce: "n", Verifier: "v", ExpiresAt: time.Now().Add(ttl)}
+		if err := repos.OIDCSessions.Create(ctx, &session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, state := range []string{"forged", "stale"} {
+		got, _, err := o.redeem(ctx, state, "code")
+		if got != nil || err == nil || !strings.HasPrefix(err.Error(), "400:") {
+			t.Fatalf("state %q: provider %v, err %v; want 400 before the exchange", state, got, err)
+		}
+	}
+	if got, _, err := o.redeem(ctx, "right", "code"); got != p || err == nil {
+		t.Fatalf("matching state: provider %v, err %v; want the exchange to be attempted", got, err)
+	}
+	if got, _, err := o.redeem(ctx, "right", "code"); got != nil || err == nil || !strings.HasPrefix(err.Error(), "400:") {
+		t.Fatalf("replayed state: provider %v, err %v; want 400", got, err)
+	}
+}
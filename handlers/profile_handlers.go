@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"finalapp/models"
+
+	"gofr.dev/pkg/gofr"
+)
+
+const (
+	maxNameLength     = 80
+	maxBioLength      = 500
+	maxLocationLength = 100
+	maxPictureURL     = 2048
+)
+
+// PublicProfile is what anyone can see about a user; it omits the email and
+// liked posts.
+type PublicProfile struct {
+	ID             string         `json:"id"`
+	Name           string         `json:"name"`
+	Bio            string         `json:"bio"`
+	Location       string         `json:"location"`
+	ProfilePicture string         `json:"profile_picture"`
+	PostCounts     map[string]int `json:"post_counts"`
+	FollowersCount int            `json:"followers_count"`
+	FollowingCount int            `json:"following_count"`
+	// FollowedByMe is set when the viewer is signed in.
+	FollowedByMe bool      `json:"followed_by_me"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (h *StoreHandlers) GetProfile(ctx *gofr.Context) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.users.FindByID(ctx, uidHex)
+	if err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	counts, err := h.postCounts(ctx, uidHex)
+	if err != nil {
+		return nil, err
+	}
+	followers, following, err := h.follows.Counts(ctx, uidHex)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"user": user, "post_counts": counts, "followers_count": followers, "following_count": following}, nil
+}
+
+func (h *StoreHandlers) UpdateProfile(ctx *gofr.Context) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var req models.ProfileUpdateRequest
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	update, err := validateProfile(req)
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.users.UpdateProfile(ctx, uidHex, update, time.Now())
+	if err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	return user, nil
+}
+
+func (h *StoreHandlers) GetUser(ctx *gofr.Context) (interface{}, error) {
+	id := ctx.PathParam("id")
+	user, err := h.users.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	counts, err := h.postCounts(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	followers, following, err := h.follows.Counts(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	followed := false
+	if viewer, ok := identity(ctx); ok {
+		if followed, err = h.follows.IsFollowing(ctx, viewer.UserID, id); err != nil {
+			return nil, fmt.Errorf("500: %v", err)
+		}
+	}
+	return PublicProfile{
+		ID:             user.ID.Hex(),
+		Name:           user.Name,
+		Bio:            user.Bio,
+		Location:       user.Location,
+		ProfilePicture: user.ProfilePicture,
+		PostCounts:     counts,
+		FollowersCount: followers,
+		FollowingCount: following,
+		FollowedByMe:   followed,
+		CreatedAt:      user.CreatedAt,
+	}, nil
+}
+
+// postCounts returns the user's post count for every known section,
+// including zeroes.
+func (h *StoreHandlers) postCounts(ctx *gofr.Context, userID string) (map[string]int, error) {
+	counts, err := h.posts.CountBySection(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	out := map[string]int{models.SectionRemedies: 0, models.SectionExperience: 0}
+	for section, n := range counts {
+		if models.ValidSection(section) {
+			out[section] = n
+		}
+	}
+	return out, nil
+}
+
+// validateProfile trims the fields and checks their lengths and the picture URL.
+func validateProfile(p models.ProfileUpdateRequest) (models.ProfileUpdateRequest, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Bio = strings.TrimSpace(p.Bio)
+	p.Location = strings.TrimSpace(p.Location)
+	p.ProfilePicture = strings.TrimSpace(p.ProfilePicture)
+	if p.Name == "" {
+		return p, fmt.Errorf("400: name required")
+	}
+	for _, f := range []struct {
+		name, value string
+		max         int
+	}{
+		{"name", p.Name, maxNameLength},
+		{"bio", p.Bio, maxBioLength},
+		{"location", p.Location, maxLocationLength},
+		{"profile_picture", p.ProfilePicture, maxPictureURL},
+	} {
+		if len([]rune(f.value)) > f.max {
+			return p, fmt.Errorf("400: %s longer than %d characters", f.name, f.max)
+		}
+	}
+	if p.ProfilePicture != "" {
+		u, err := url.Parse(p.ProfilePicture)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return p, fmt.Errorf("400: profile_picture must be an http(s) URL")
+		}
+	}
+	return p, nil
+}
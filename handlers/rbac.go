@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"finalapp/models"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// Permission is an action on the Neighbour network. Routes declare the
+// permission they need with RequirePermission; ownership rules (only the
+// owning elder confirms, only the assigned helper claims) are checked in the
+// handlers because they depend on the request document.
+//
+// Caregivers acting for an elder hold a delegated token instead of a role;
+// it grants only the scopes the elder delegated.
+type Permission string
+
+const (
+	PermCreateRequest  Permission = "request:create"
+	PermViewRequests   Permission = "request:view"
+	PermAssignRequest  Permission = "request:assign"
+	PermConfirmRequest Permission = "request:confirm"
+	PermViewHistory    Permission = "history:view"
+	PermClaimReward    Permission = "reward:claim"
+	PermManageRoles    Permission = "roles:manage"
+	PermManageAccounts Permission = "accounts:manage"
+	PermDelegate       Permission = "delegation:grant"
+	PermManageDevices  Permission = "devices:manage"
+	PermManageTags     Permission = "tags:manage"
+	// PermAudioChat is only checked for devices; the chatbot is public.
+	PermAudioChat Permission = "audio:chat"
+)
+
+var rolePermissions = map[string][]Permission{
+	models.RoleElder:       {PermCreateRequest, PermConfirmRequest, PermViewHistory, PermDelegate},
+	models.RoleHelper:      {PermViewRequests, PermAssignRequest, PermClaimReward},
+	models.RoleCoordinator: {PermCreateRequest, PermViewRequests, PermAssignRequest, PermViewHistory, PermManageDevices},
+	models.RoleAdmin: {
+		PermCreateRequest, PermViewRequests, PermAssignRequest, PermConfirmRequest,
+		PermViewHistory, PermClaimReward, PermManageRoles, PermManageAccounts, PermManageDevices,
+		PermManageTags,
+	},
+}
+
+// delegableScopes are the permissions an elder can hand to a caregiver.
+var delegableScopes = []Permission{PermCreateRequest, PermConfirmRequest, PermViewHistory}
+
+// HasPermission reports whether role grants p.
+func HasPermission(role string, p Permission) bool {
+	return hasScope(rolePermissions[role], p)
+}
+
+func hasScope(scopes []Permission, p Permission) bool {
+	for _, s := range scopes {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
+
+// parseScopes checks that every requested scope is in allowed and returns
+// them without duplicates.
+func parseScopes(requested []string, allowed []Permission) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, fmt.Errorf("400: at least one scope required")
+	}
+	scopes := make([]string, 0, len(requested))
+	seen := map[string]bool{}
+	for _, s := range requested {
+		if !hasScope(allowed, Permission(s)) {
+			return nil, fmt.Errorf("400: scope %q is not allowed", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+// intersectScopes returns the scopes in have that granted still allows.
+func intersectScopes(have []Permission, granted []string) []Permission {
+	var out []Permission
+	for _, p := range have {
+		for _, g := range granted {
+			if string(p) == g {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// RequirePermission wraps a handler so it only runs for callers whose role,
+// or delegated or device scopes, grant p. Anonymous callers get 401, others
+// lacking p get 403.
+func RequirePermission(p Permission, next func(*gofr.Context) (interface{}, error)) func(*gofr.Context) (interface{}, error) {
+	return func(ctx *gofr.Context) (interface{}, error) {
+		id, ok := identity(ctx)
+		if err := authorize(id, ok, p); err != nil {
+			return nil, err
+		}
+		return next(ctx)
+	}
+}
+
+// authorize is the check RequirePermission makes for the caller id, where ok
+// is false for anonymous callers.
+func authorize(id Identity, ok bool, p Permission) error {
+	if !ok {
+		return fmt.Errorf("401: invalid token")
+	}
+	if id.scoped() {
+		if !hasScope(id.Scopes, p) {
+			return fmt.Errorf("403: token scopes do not allow %s", p)
+		}
+	} else if !HasPermission(id.Role, p) {
+		return fmt.Errorf("403: role %q may not %s", id.Role, p)
+	}
+	return nil
+}
+
+// SetUserRole lets an admin grant any role, including coordinator and admin.
+func (h *NeighbourHandlers) SetUserRole(ctx *gofr.Context) (interface{}, error) {
+	var body struct {
+		UserID string `json:"userId"`
+		Role   string `json:"role"`
+	}
+	if err := ctx.Bind(&body); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	if !models.ValidRole(body.Role) {
+		return nil, fmt.Errorf("400: unknown role %q", body.Role)
+	}
+	if err := h.setRole(ctx, body.UserID, body.Role); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"message": "Role updated", "userId": body.UserID, "role": body.Role}, nil
+}
+
+// setRole changes the user's role and ends their sessions. Access tokens
+// carry the role, so without this a demoted user would keep the old role
+// until their token expired, and a promoted one would skip enrolling a second
+// factor by refreshing.
+func (h *NeighbourHandlers) setRole(ctx context.Context, userID, role string) error {
+	user, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		return notFoundOr500(err, "user")
+	}
+	if user.Role == role {
+		return nil
+	}
+	if err := h.users.SetRole(ctx, userID, role); err != nil {
+		return notFoundOr500(err, "user")
+	}
+	if err := h.tokens.repo.RevokeUser(ctx, userID, time.Now()); err != nil {
+		return fmt.Errorf("500: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"finalapp/models"
+	"finalapp/store"
+)
+
+func newTestTokens(t *testing.T, repos store.Repositories) *Tokens {
+	t.Helper()
+	keys, err := LoadKeySet("test secret that is long enough to sign with", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTokens(repos, keys)
+}
+
+// authorizeRequest runs a request carrying access through the auth
+// middleware and returns the result of the check RequirePermission makes.
+func authorizeRequest(tokens *Tokens, access string, p Permission) error {
+	var err error
+	h := tokens.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := r.Context().Value(identityKey{}).(Identity)
+		err = authorize(id, ok, p)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+access)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	return err
+}
+
+func TestDemotionEndsSessions(t *testing.T) {
+	ctx := context.Background()
+	repos := store.NewMemoryRepositories()
+	tokens := newTestTokens(t, repos)
+	h := NewNeighbourHandlers(repos, nil, tokens)
+	admin := models.User{Email: "root@example.com", Role: models.RoleAdmin}
+	if err := repos.Users.Create(ctx, &admin); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tokens.issue(ctx, &admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := authorizeRequest(tokens, pair.AccessToken, PermManageRoles); err != nil {
+		t.Fatalf("admin refused before demotion: %v", err)
+	}
+
+	if err := h.setRole(ctx, admin.ID.Hex(), models.RoleHelper); err != nil {
+		t.Fatal(err)
+	}
+	if err := authorizeRequest(tokens, pair.AccessToken, PermManageRoles); err == nil || !strings.HasPrefix(err.Error(), "401:") {
+		t.Fatalf("token issued before demotion: err = %v, want 401", err)
+	}
+	if _, err := tokens.rotate(ctx, pair.RefreshToken); err == nil {
+		t.Fatal("refresh token issued before demotion still works")
+	}
+	got, _ := repos.Users.FindByID(ctx, admin.ID.Hex())
+	if got.Role != models.RoleHelper {
+		t.Fatalf("role = %q, want helper", got.Role)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"finalapp/mailer"
+	"finalapp/models"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+var errInvalidAccountToken = errors.New("invalid or expired token")
+
+// RequestVerification re-sends the verification email to the signed-in user.
+func (a *Accounts) RequestVerification(ctx *gofr.Context) (interface{}, error) {
+	uid, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.users.FindByID(ctx, uid)
+	if err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	if user.EmailVerified {
+		return map[string]interface{}{"message": "Email already verified"}, nil
+	}
+	if err := a.sendVerification(ctx, user); err != nil {
+		return nil, fmt.Errorf("503: could not send verification email: %v", err)
+	}
+	return map[string]interface{}{"message": "Verification email sent"}, nil
+}
+
+func (a *Accounts) ConfirmVerification(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	if err := a.verifyEmail(ctx, req.Token); err != nil {
+		if errors.Is(err, errInvalidAccountToken) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"message": "Email verified"}, nil
+}
+
+// RequestPasswordReset always answers the same way so the endpoint cannot be
+// used to find out which addresses have accounts.
+func (a *Accounts) RequestPasswordReset(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	if normalizeEmail(req.Email) == "" {
+		return nil, fmt.Errorf("400: email required")
+	}
+	if err := a.requestPasswordReset(ctx, req.Email); err != nil {
+		ctx.Logger.Errorf("password reset for %s: %v", normalizeEmail(req.Email), err)
+	}
+	return map[string]interface{}{"message": "If that address has an account, a reset link is on its way"}, nil
+}
+
+func (a *Accounts) ConfirmPasswordReset(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	if err := a.resetPassword(ctx, req.Token, req.Password); err != nil {
+		if errors.Is(err, errInvalidAccountToken) || errors.Is(err, errWeakPassword) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"message": "Password updated, please sign in again"}, nil
+}
+
+// sendVerification mails the user a link to confirm their email address.
+// Earlier verification links stop working.
+func (a *Accounts) sendVerification(ctx context.Context, user *models.User) error {
+	tok, err := a.issueAccountToken(ctx, user.ID.Hex(), models.PurposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return a.mail.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link within 24 hours:\n\n%s/?verify_token=%s\n\nIf you did not sign up, you can ignore this email.",
+			user.Name, a.baseURL, tok),
+	})
+}
+
+func (a *Accounts) verifyEmail(ctx context.Context, token string) error {
+	t, err := a.consume(ctx, token, models.PurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+	return a.users.SetEmailVerified(ctx, t.UserID)
+}
+
+// requestPasswordReset mails a reset link if email belongs to an account.
+// An unknown address is not an error.
+func (a *Accounts) requestPasswordReset(ctx context.Context, email string) error {
+	user, err := a.users.FindByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tok, err := a.issueAccountToken(ctx, user.ID.Hex(), models.PurposeResetPassword, resetPasswordTTL)
+	if err != nil {
+		return err
+	}
+	return a.mail.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. Open this link within an hour to choose a new one:\n\n%s/?reset_token=%s\n\nIf it wasn't you, ignore this email and your password stays the same.",
+			user.Name, a.baseURL, tok),
+	})
+}
+
+// resetPassword sets a new password and signs the user out everywhere. The
+// reset link arrived by email, so it also counts as verifying the address.
+func (a *Accounts) resetPassword(ctx context.Context, token, password string) error {
+	// Checked first so a rejected password leaves the token usable.
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	t, err := a.consume(ctx, token, models.PurposeResetPassword)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := a.users.SetPasswordHash(ctx, t.UserID, string(hash)); err != nil {
+		return err
+	}
+	if err := a.users.SetEmailVerified(ctx, t.UserID); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := a.actions.InvalidateUser(ctx, t.UserID, models.PurposeResetPassword, now); err != nil {
+		return err
+	}
+	return a.tokens.RevokeUser(ctx, t.UserID, now)
+}
+
+// issueAccountToken creates a one-time token for purpose, invalidating any
+// the user still holds for the same purpose.
+func (a *Accounts) issueAccountToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := a.actions.InvalidateUser(ctx, userID, purpose, now); err != nil {
+		return "", err
+	}
+	tok := randomToken(32)
+	t := models.AccountToken{Hash: hashToken(tok), UserID: userID, Purpose: purpose, ExpiresAt: now.Add(ttl), CreatedAt: now}
+	if err := a.actions.Create(ctx, &t); err != nil {
+		return "", err
+	}
+	return tok, nil
+}
+
+func (a *Accounts) consume(ctx context.Context, token, purpose string) (*models.AccountToken, error) {
+	if token == "" {
+		return nil, errInvalidAccountToken
+	}
+	t, err := a.actions.Consume(ctx, hashToken(token), purpose, time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, errInvalidAccountToken
+	}
+	return t, err
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"finalapp/models"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+)
+
+const (
+	maxSearchQueryLen = 200
+	// snippetRunes is roughly how much post text a search result shows.
+	snippetRunes = 160
+)
+
+type searchResult struct {
+	models.Post
+	Score float64 `json:"score"`
+	// Snippet is HTML: the post text around the first match, escaped,
+	// with matching words wrapped in <mark>.
+	Snippet string `json:"snippet"`
+}
+
+// Search finds posts by words in their content, tags or author name, most
+// relevant first, optionally within one section.
+func (h *StoreHandlers) Search(ctx *gofr.Context) (interface{}, error) {
+	text := strings.TrimSpace(ctx.Param("q"))
+	if text == "" {
+		return nil, fmt.Errorf("400: q is required")
+	}
+	if utf8.RuneCountInString(text) > maxSearchQueryLen {
+		return nil, fmt.Errorf("400: q is at most %d characters", maxSearchQueryLen)
+	}
+	terms := store.SearchTerms(text)
+	if len(terms) == 0 {
+		return map[string]interface{}{"results": []searchResult{}}, nil
+	}
+	section, err := sectionParam(ctx.Param("section"))
+	if err != nil {
+		return nil, err
+	}
+	q := store.SearchQuery{Text: text, Section: section, Cursor: ctx.Param("cursor")}
+	if l := ctx.Param("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("400: invalid limit")
+		}
+		q.Limit = n
+	}
+	page, err := h.posts.Search(ctx, q)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	posts := make([]models.Post, len(page.Hits))
+	for i, hit := range page.Hits {
+		posts[i] = hit.Post
+	}
+	if viewer, ok := identity(ctx); ok {
+		markLiked(posts, viewer.UserID)
+	}
+	results := make([]searchResult, len(posts))
+	for i, p := range posts {
+		results[i] = searchResult{Post: p, Score: page.Hits[i].Score, Snippet: snippet(p.Content, terms)}
+	}
+	return map[string]interface{}{"results": results, "next_cursor": page.NextCursor}, nil
+}
+
+// snippet returns about snippetRunes of text starting a little before the
+// first word matching terms, HTML-escaped, with matches marked.
+func snippet(text string, terms []string) string {
+	want := map[string]bool{}
+	for _, t := range terms {
+		want[t] = true
+	}
+	runes := []rune(text)
+	type span struct{ start, end int }
+	var matches []span
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		if want[store.Stem(strings.ToLower(string(runes[i:j])))] {
+			matches = append(matches, span{i, j})
+		}
+		i = j
+	}
+	start := 0
+	if len(matches) > 0 && matches[0].start > snippetRunes/4 {
+		start = matches[0].start - snippetRunes/4
+		// Begin on a word boundary.
+		for start < matches[0].start && isWordRune(runes[start-1]) {
+			start++
+		}
+	}
+	end := min(start+snippetRunes, len(runes))
+	for end < len(runes) && isWordRune(runes[end]) && end-start < snippetRunes+20 {
+		end++
+	}
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.start < start {
+			continue
+		}
+		if m.end > end {
+			break
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		b.WriteString("<mark>" + html.EscapeString(string(runes[m.start:m.end])) + "</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
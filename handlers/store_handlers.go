@@ -2,21 +2,39 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"finalapp/models"
+	"finalapp/ranking"
 	"finalapp/store"
 
-	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"gofr.dev/pkg/gofr"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func SignUp(ctx *gofr.Context) (interface{}, error) {
+// StoreHandlers serves the community feed endpoints. Storage is injected so
+// the handlers can run against MongoDB or the in-memory repositories.
+type StoreHandlers struct {
+	accounts *Accounts
+	tokens   *Tokens
+	users    store.UserRepository
+	posts    store.PostRepository
+	comments store.CommentRepository
+	trends   store.TrendRepository
+	taxonomy store.TaxonomyRepository
+	follows  store.FollowRepository
+}
+
+func NewStoreHandlers(repos store.Repositories, accounts *Accounts, tokens *Tokens) *StoreHandlers {
+	return &StoreHandlers{accounts: accounts, tokens: tokens, users: repos.Users, posts: repos.Posts, comments: repos.Comments, trends: repos.Trends, taxonomy: repos.Taxonomy, follows: repos.Follows}
+}
+
+func (h *StoreHandlers) SignUp(ctx *gofr.Context) (interface{}, error) {
 	var req struct {
 		Name     string `json:"name"`
 		Email    string `json:"email"`
@@ -25,56 +43,66 @@ func SignUp(ctx *gofr.Context) (interface{}, error) {
 	if err := ctx.Bind(&req); err != nil {
 		return nil, fmt.Errorf("400: %v", err)
 	}
-	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
-	if req.Email == "" || req.Password == "" {
+	if normalizeEmail(req.Email) == "" || req.Password == "" {
 		return nil, fmt.Errorf("400: email/password required")
 	}
-	var existing models.User
-	err := store.UsersCollection.FindOne(ctx.Request.Context(), bson.M{"email": req.Email}).Decode(&existing)
-	if err == nil {
-		return nil, fmt.Errorf("409: user already exists")
-	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	user, err := h.accounts.Register(ctx, req.Name, req.Email, req.Password, "")
 	if err != nil {
+		if errors.Is(err, errInvalidEmail) || errors.Is(err, errWeakPassword) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		if errors.Is(err, errAccountExists) {
+			return nil, fmt.Errorf("409: %v", err)
+		}
 		return nil, fmt.Errorf("500: %v", err)
 	}
-	user := models.User{Name: req.Name, Email: req.Email, Password: string(hash), CreatedAt: time.Now()}
-	res, err := store.UsersCollection.InsertOne(ctx.Request.Context(), user)
-	if err != nil {
-		return nil, fmt.Errorf("500: %v", err)
+	// Sign-up still succeeds if the mail is lost; the user can ask for another.
+	if err := h.accounts.sendVerification(ctx, user); err != nil {
+		ctx.Logger.Errorf("sending verification to %s: %v", user.Email, err)
 	}
-	oid := res.InsertedID.(primitive.ObjectID)
-	token, err := generateToken(oid)
+	pair, err := h.tokens.issue(ctx, user)
 	if err != nil {
 		return nil, fmt.Errorf("500: %v", err)
 	}
-	return map[string]interface{}{"user_id": oid.Hex(), "token": token}, nil
+	return map[string]interface{}{"user_id": user.ID.Hex(), "token": pair.AccessToken, "refresh_token": pair.RefreshToken, "expires_in": pair.ExpiresIn}, nil
 }
 
-func Login(ctx *gofr.Context) (interface{}, error) {
+func (h *StoreHandlers) Login(ctx *gofr.Context) (interface{}, error) {
 	var req struct{ Email, Password string }
 	if err := ctx.Bind(&req); err != nil {
 		return nil, fmt.Errorf("400: %v", err)
 	}
-	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
-	var user models.User
-	err := store.UsersCollection.FindOne(ctx.Request.Context(), bson.M{"email": req.Email}).Decode(&user)
+	user, err := h.accounts.SignIn(ctx, req.Email, req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("404: invalid credentials")
+		if errors.Is(err, errInvalidCredentials) {
+			return nil, fmt.Errorf("404: invalid credentials")
+		}
+		var locked *lockedError
+		if errors.As(err, &locked) {
+			return nil, fmt.Errorf("429: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	challenge, err := h.tokens.secondStep(user)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("404: invalid credentials")
+	if challenge != nil {
+		return challenge, nil
 	}
-	token, err := generateToken(user.ID)
+	pair, err := h.tokens.issue(ctx, user)
 	if err != nil {
 		return nil, fmt.Errorf("500: %v", err)
 	}
-	return map[string]interface{}{"user_id": user.ID.Hex(), "token": token}, nil
+	return map[string]interface{}{"user_id": user.ID.Hex(), "token": pair.AccessToken, "refresh_token": pair.RefreshToken, "expires_in": pair.ExpiresIn}, nil
 }
 
-func CreatePost(ctx *gofr.Context) (interface{}, error) {
+func (h *StoreHandlers) CreatePost(ctx *gofr.Context) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var req struct {
-		Token     string   `json:"token"`
 		MediaURL  string   `json:"media_url"`
 		MediaType string   `json:"media_type"`
 		Section   string   `json:"section"`
@@ -84,20 +112,15 @@ func CreatePost(ctx *gofr.Context) (interface{}, error) {
 	if err := ctx.Bind(&req); err != nil {
 		return nil, fmt.Errorf("400: %v", err)
 	}
-	uidHex, err := parseToken(req.Token)
-	if err != nil {
-		return nil, fmt.Errorf("401: invalid token")
+	if !models.ValidSection(req.Section) {
+		return nil, errInvalidSection
 	}
-	userOID, err := primitive.ObjectIDFromHex(uidHex)
+	user, err := h.users.FindByID(ctx, uidHex)
 	if err != nil {
-		return nil, fmt.Errorf("400: invalid user ID")
-	}
-	var user models.User
-	if err := store.UsersCollection.FindOne(ctx, bson.M{"_id": userOID}).Decode(&user); err != nil {
 		return nil, fmt.Errorf("404: User not found")
 	}
 	textForHash := req.Content
-	if req.MediaURL != "" && (req.MediaType == "audio" || req.MediaType == "video") {
+	if hasSpokenMedia(req.MediaURL, req.MediaType) {
 		if transcript, err := TranscribeElevenLabs(req.MediaURL); err == nil {
 			textForHash = transcript
 		}
@@ -105,77 +128,305 @@ func CreatePost(ctx *gofr.Context) (interface{}, error) {
 	var hashtags []string
 	if textForHash != "" {
 		if tags, err := GenerateHashtags(context.Background(), textForHash); err == nil {
-			hashtags = tags
+			hashtags = h.canonicalTags(ctx, tags)
 		}
 	}
 	post := models.Post{UserID: uidHex, UserName: user.Name, MediaURL: req.MediaURL, MediaType: req.MediaType, Content: req.Content, Tags: hashtags, Section: req.Section, CreatedAt: time.Now()}
-	res, err := store.PostsCollection.InsertOne(ctx, post)
-	if err != nil {
+	if err := h.posts.Create(ctx, &post); err != nil {
 		return nil, fmt.Errorf("500: %v", err)
 	}
-	post.ID = res.InsertedID.(primitive.ObjectID)
 	return post, nil
 }
 
-func GetFeed(ctx *gofr.Context) (interface{}, error) {
-	cur, err := store.PostsCollection.Find(ctx.Request.Context(), bson.M{}, nil)
+func (h *StoreHandlers) UpdatePost(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	post, err := h.ownPost(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("500: %v", err)
+		return nil, err
+	}
+	if strings.TrimSpace(req.Content) == "" && post.MediaURL == "" {
+		return nil, fmt.Errorf("400: content required for a post without media")
+	}
+	tags := post.Tags
+	// Audio and video posts are tagged from their transcript, which an edit
+	// to the caption does not change.
+	if req.Content != post.Content && req.Content != "" && !hasSpokenMedia(post.MediaURL, post.MediaType) {
+		if generated, err := GenerateHashtags(context.Background(), req.Content); err == nil {
+			tags = h.canonicalTags(ctx, generated)
+		}
 	}
-	defer cur.Close(ctx.Request.Context())
-	var posts []models.Post
-	if err := cur.All(ctx.Request.Context(), &posts); err != nil {
+	updated, err := h.posts.UpdateContent(ctx, post.ID.Hex(), req.Content, tags, time.Now())
+	if err != nil {
+		return nil, notFoundOr500(err, "post")
+	}
+	return updated, nil
+}
+
+func (h *StoreHandlers) DeletePost(ctx *gofr.Context) (interface{}, error) {
+	post, err := h.ownPost(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.posts.SoftDelete(ctx, post.ID.Hex(), time.Now()); err != nil {
+		return nil, notFoundOr500(err, "post")
+	}
+	return map[string]interface{}{"message": "Post deleted"}, nil
+}
+
+// ownPost loads the post named in the path and checks that the caller is its
+// author.
+func (h *StoreHandlers) ownPost(ctx *gofr.Context) (*models.Post, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	post, err := h.posts.FindByID(ctx, ctx.PathParam("id"))
+	if err != nil {
+		return nil, notFoundOr500(err, "post")
+	}
+	if post.UserID != uidHex {
+		return nil, fmt.Errorf("403: not the post author")
+	}
+	return post, nil
+}
+
+func (h *StoreHandlers) GetFeed(ctx *gofr.Context) (interface{}, error) {
+	q, err := feedParams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if t := ctx.Param("tag"); t != "" {
+		if q.Tag, err = h.canonicalTag(ctx, t); err != nil {
+			return nil, err
+		}
+	}
+	q.UserID = ctx.Param("author")
+	page, err := h.posts.Feed(ctx, q)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
 		return nil, fmt.Errorf("500: %v", err)
 	}
-	return posts, nil
+	// The feed is public; a token only personalizes liked_by_me.
+	if viewer, ok := identity(ctx); ok {
+		markLiked(page.Posts, viewer.UserID)
+	}
+	return page, nil
 }
 
-func GetUserPosts(ctx *gofr.Context) (interface{}, error) {
-	var req struct {
-		Token string `json:"token"`
+// feedParams reads the paging, sort and section parameters shared by the
+// feeds.
+func feedParams(ctx *gofr.Context) (store.FeedQuery, error) {
+	q := store.FeedQuery{Cursor: ctx.Param("cursor"), Sort: ctx.Param("sort")}
+	section, err := sectionParam(ctx.Param("section"))
+	if err != nil {
+		return q, err
 	}
-	if err := ctx.Bind(&req); err != nil {
-		return nil, fmt.Errorf("400: %v", err)
+	q.Section = section
+	if l := ctx.Param("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("400: invalid limit")
+		}
+		q.Limit = n
+	}
+	if q.Sort != "" && !store.ValidSort(q.Sort) {
+		return q, fmt.Errorf("400: sort must be %q or %q", store.SortNewest, store.SortMostLiked)
+	}
+	return q, nil
+}
+
+const (
+	// forYouWindow and forYouCandidates bound the posts the for-you feed
+	// ranks: the most recent candidates within the window.
+	forYouWindow     = 14 * 24 * time.Hour
+	forYouCandidates = 500
+	// forYouLikedPosts is how many of the reader's latest likes shape their
+	// tag affinities.
+	forYouLikedPosts = 200
+)
+
+// forYouCursor pins the ranking time so later pages rank the same candidate
+// set the same way.
+type forYouCursor struct {
+	At     int64 `json:"a"`
+	Offset int   `json:"o"`
+}
+
+// GetForYouFeed ranks recent posts for the reader by their preferred tags,
+// the tags of posts they liked, engagement and recency. Without a signed-in
+// user only engagement and recency count.
+func (h *StoreHandlers) GetForYouFeed(ctx *gofr.Context) (interface{}, error) {
+	section, err := sectionParam(ctx.Param("section"))
+	if err != nil {
+		return nil, err
+	}
+	limit := store.DefaultFeedLimit
+	if l := ctx.Param("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("400: invalid limit")
+		}
+		limit = min(n, store.MaxFeedLimit)
+	}
+	viewer, _ := identity(ctx)
+	return h.forYouPage(ctx, viewer.UserID, section, ctx.Param("cursor"), limit, time.Now())
+}
+
+// forYouPage ranks the candidates as of now, or as of the time pinned in
+// cursor, and returns the page at the cursor's offset.
+func (h *StoreHandlers) forYouPage(ctx context.Context, viewerID, section, cursor string, limit int, now time.Time) (store.FeedPage, error) {
+	cur := forYouCursor{At: now.Unix()}
+	if cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || json.Unmarshal(b, &cur) != nil || cur.Offset < 0 {
+			return store.FeedPage{}, fmt.Errorf("400: %v", store.ErrInvalidCursor)
+		}
+	}
+	now = time.Unix(cur.At, 0)
+	candidates, err := h.posts.Recent(ctx, section, now.Add(-forYouWindow), now, forYouCandidates)
+	if err != nil {
+		return store.FeedPage{}, fmt.Errorf("500: %v", err)
+	}
+	prof, err := h.readerProfile(ctx, viewerID)
+	if err != nil {
+		return store.FeedPage{}, fmt.Errorf("500: %v", err)
+	}
+	ranked := ranking.Rank(candidates, prof, cfg.FeedRanking, now)
+	page := store.FeedPage{Posts: []models.Post{}}
+	for i := cur.Offset; i < len(ranked) && i < cur.Offset+limit; i++ {
+		page.Posts = append(page.Posts, ranked[i].Post)
+	}
+	if cur.Offset+limit < len(ranked) {
+		b, _ := json.Marshal(forYouCursor{At: cur.At, Offset: cur.Offset + limit})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(b)
+	}
+	markLiked(page.Posts, viewerID)
+	return page, nil
+}
+
+// readerProfile loads the interests of the signed-in reader. Anonymous
+// callers, devices and demo identities get an empty profile.
+func (h *StoreHandlers) readerProfile(ctx context.Context, userID string) (ranking.Profile, error) {
+	if userID == "" {
+		return ranking.NewProfile(nil, nil), nil
+	}
+	user, err := h.users.FindByID(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return ranking.NewProfile(nil, nil), nil
+	}
+	if err != nil {
+		return ranking.Profile{}, err
+	}
+	likedIDs := user.LikedPosts
+	if len(likedIDs) > forYouLikedPosts {
+		likedIDs = likedIDs[len(likedIDs)-forYouLikedPosts:]
 	}
-	uidHex, err := parseToken(req.Token)
+	liked, err := h.posts.FindByIDs(ctx, likedIDs)
 	if err != nil {
-		return nil, fmt.Errorf("401: invalid token")
+		return ranking.Profile{}, err
 	}
-	section := ctx.Param("section")
-	filter := bson.M{"userid": uidHex}
-	if section != "" {
-		filter = bson.M{"userid": uidHex, "section": section}
+	// Preferred tags may be spelled as aliases; stored tags never are.
+	tax, err := h.loadTaxonomy(ctx)
+	if err != nil {
+		return ranking.Profile{}, err
 	}
-	cur, err := store.PostsCollection.Find(ctx, filter)
+	return ranking.NewProfile(tax.Apply(user.PreferredTags, -1), liked), nil
+}
+
+func (h *StoreHandlers) LikePost(ctx *gofr.Context) (interface{}, error) {
+	return h.setLike(ctx, true)
+}
+
+func (h *StoreHandlers) UnlikePost(ctx *gofr.Context) (interface{}, error) {
+	return h.setLike(ctx, false)
+}
+
+func (h *StoreHandlers) setLike(ctx *gofr.Context, liked bool) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
 	if err != nil {
+		return nil, err
+	}
+	postID := ctx.PathParam("id")
+	like, undo := h.posts.Like, h.posts.Unlike
+	if !liked {
+		like, undo = undo, like
+	}
+	post, changed, err := like(ctx, postID, uidHex)
+	if err != nil {
+		return nil, notFoundOr500(err, "post")
+	}
+	if err := h.users.SetLikedPost(ctx, uidHex, postID, liked); err != nil {
+		// Put the post back so its likes keep matching the user's
+		// liked_posts.
+		if changed {
+			if _, _, undoErr := undo(ctx, postID, uidHex); undoErr != nil {
+				ctx.Logger.Errorf("reverting like of %s by %s: %v", postID, uidHex, undoErr)
+			}
+		}
 		return nil, fmt.Errorf("500: %v", err)
 	}
-	defer cur.Close(ctx)
-	var posts []models.Post
-	if err := cur.All(ctx, &posts); err != nil {
+	post.LikedByMe = liked
+	return post, nil
+}
+
+// markLiked sets LikedByMe on each post the viewer has liked.
+func markLiked(posts []models.Post, viewer string) {
+	for i := range posts {
+		for _, id := range posts[i].LikedBy {
+			if id == viewer {
+				posts[i].LikedByMe = true
+				break
+			}
+		}
+	}
+}
+
+func (h *StoreHandlers) GetUserPosts(ctx *gofr.Context) (interface{}, error) {
+	uidHex, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	section, err := sectionParam(ctx.Param("section"))
+	if err != nil {
+		return nil, err
+	}
+	posts, err := h.posts.ListByUser(ctx, uidHex, section)
+	if err != nil {
 		return nil, fmt.Errorf("500: %v", err)
 	}
 	return posts, nil
 }
 
-func generateToken(userID primitive.ObjectID) (string, error) {
-	claims := jwt.MapClaims{"user_id": userID.Hex(), "exp": time.Now().Add(24 * time.Hour).Unix()}
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return t.SignedString([]byte(cfg.JWTSecret))
+// hasSpokenMedia reports whether the post's media is transcribed for tagging.
+func hasSpokenMedia(mediaURL, mediaType string) bool {
+	return mediaURL != "" && (mediaType == "audio" || mediaType == "video")
 }
 
-func parseToken(tok string) (string, error) {
-	if tok == "" {
-		return "", fmt.Errorf("token missing")
+var errInvalidSection = fmt.Errorf("400: section must be %q or %q", models.SectionRemedies, models.SectionExperience)
+
+// sectionParam validates an optional section query parameter. Empty and "all"
+// both mean no filter.
+func sectionParam(s string) (string, error) {
+	if s == "" || s == "all" {
+		return "", nil
 	}
-	parsed, err := jwt.Parse(tok, func(token *jwt.Token) (interface{}, error) { return []byte(cfg.JWTSecret), nil })
-	if err != nil {
-		return "", err
+	if !models.ValidSection(s) {
+		return "", errInvalidSection
 	}
-	if claims, ok := parsed.Claims.(jwt.MapClaims); ok && parsed.Valid {
-		if uid, ok := claims["user_id"].(string); ok {
-			return uid, nil
-		}
+	return s, nil
+}
+
+// notFoundOr500 maps store.ErrNotFound to a 404 naming what was missing.
+func notFoundOr500(err error, what string) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("404: %s not found", what)
 	}
-	return "", fmt.Errorf("invalid token claims")
+	return fmt.Errorf("500: %v", err)
 }
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"finalapp/hashtag"
+	"finalapp/models"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// Post tags are normalized before they are stored and, once admins have
+// curated a taxonomy, mapped onto it: aliases become their canonical tag and
+// tags outside the taxonomy are dropped. Until the taxonomy has an entry,
+// every cleaned tag is kept.
+
+func (h *StoreHandlers) loadTaxonomy(ctx context.Context) (*hashtag.Taxonomy, error) {
+	defs, err := h.taxonomy.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return hashtag.NewTaxonomy(defs), nil
+}
+
+// canonicalTags turns generated tags into the ones stored on a post. If the
+// taxonomy cannot be read the tags are still normalized, so a post is never
+// lost over its tags.
+func (h *StoreHandlers) canonicalTags(ctx *gofr.Context, raw []string) []string {
+	tax, err := h.loadTaxonomy(ctx)
+	if err != nil {
+		ctx.Logger.Errorf("loading tag taxonomy: %v", err)
+		return hashtag.Normalize(raw, hashtag.MaxPerPost)
+	}
+	return tax.Apply(raw, hashtag.MaxPerPost)
+}
+
+var errInvalidTag = fmt.Errorf("400: tag must have at least two letters or digits")
+
+// canonicalTag maps a tag a reader asked for (any case, with or without '#',
+// or an alias) to the form posts store it in, so lookups agree with
+// canonicalTags.
+func (h *StoreHandlers) canonicalTag(ctx context.Context, raw string) (string, error) {
+	tag := hashtag.Clean(raw)
+	if tag == "" {
+		return "", errInvalidTag
+	}
+	tax, err := h.loadTaxonomy(ctx)
+	if err != nil {
+		return "", fmt.Errorf("500: %v", err)
+	}
+	if c, ok := tax.Canonical(tag); ok {
+		return c, nil
+	}
+	return tag, nil
+}
+
+// ListTagDefinitions returns the taxonomy, alphabetically.
+func (h *StoreHandlers) ListTagDefinitions(ctx *gofr.Context) (interface{}, error) {
+	defs, err := h.taxonomy.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	if defs == nil {
+		defs = []models.TagDefinition{}
+	}
+	return defs, nil
+}
+
+// PutTagDefinition creates or replaces a canonical tag and its aliases. An
+// alias may belong to only one tag and may not itself be a canonical tag.
+func (h *StoreHandlers) PutTagDefinition(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Aliases     []string `json:"aliases"`
+		Description string   `json:"description"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	tag := hashtag.Clean(ctx.PathParam("tag"))
+	if tag == "" {
+		return nil, errInvalidTag
+	}
+	aliases := []string{}
+	seen := map[string]bool{tag: true}
+	for _, raw := range req.Aliases {
+		a := hashtag.Clean(raw)
+		if a == "" {
+			return nil, fmt.Errorf("400: invalid alias %q", raw)
+		}
+		if !seen[a] {
+			seen[a] = true
+			aliases = append(aliases, a)
+		}
+	}
+	defs, err := h.taxonomy.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	owner := map[string]string{}
+	for _, d := range defs {
+		if d.Tag == tag {
+			continue
+		}
+		owner[d.Tag] = d.Tag
+		for _, a := range d.Aliases {
+			owner[a] = d.Tag
+		}
+	}
+	if other, ok := owner[tag]; ok {
+		return nil, fmt.Errorf("409: %s is an alias of %s", tag, other)
+	}
+	for _, a := range aliases {
+		if other, ok := owner[a]; ok {
+			if other == a {
+				return nil, fmt.Errorf("409: %s is already a canonical tag", a)
+			}
+			return nil, fmt.Errorf("409: %s is already an alias of %s", a, other)
+		}
+	}
+	def := models.TagDefinition{Tag: tag, Aliases: aliases, Description: req.Description, UpdatedAt: time.Now()}
+	if err := h.taxonomy.Put(ctx, &def); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return def, nil
+}
+
+// DeleteTagDefinition removes a canonical tag and its aliases from the
+// taxonomy. Posts keep their tags until they are retagged.
+func (h *StoreHandlers) DeleteTagDefinition(ctx *gofr.Context) (interface{}, error) {
+	tag := hashtag.Clean(ctx.PathParam("tag"))
+	if err := h.taxonomy.Delete(ctx, tag); err != nil {
+		return nil, notFoundOr500(err, "tag")
+	}
+	return map[string]interface{}{"message": "Tag deleted"}, nil
+}
+
+// RetagPosts applies the current taxonomy to the tags already stored on
+// every post and returns how many posts changed.
+func (h *StoreHandlers) RetagPosts(ctx context.Context) (int, error) {
+	tax, err := h.loadTaxonomy(ctx)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	q := store.FeedQuery{Limit: store.MaxFeedLimit}
+	for {
+		page, err := h.posts.Feed(ctx, q)
+		if err != nil {
+			return changed, err
+		}
+		for _, p := range page.Posts {
+			tags := tax.Apply(p.Tags, hashtag.MaxPerPost)
+			if slices.Equal(tags, p.Tags) {
+				continue
+			}
+			err := h.posts.SetTags(ctx, p.ID.Hex(), tags)
+			if errors.Is(err, store.ErrNotFound) {
+				// Deleted since the page was read.
+				continue
+			}
+			if err != nil {
+				return changed, fmt.Errorf("retagging %s: %w", p.ID.Hex(), err)
+			}
+			changed++
+		}
+		if page.NextCursor == "" {
+			return changed, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
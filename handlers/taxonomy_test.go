@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+)
+
+func newTaxonomyHandlers(t *testing.T) (*StoreHandlers, store.Repositories) {
+	t.Helper()
+	repos := store.NewMemoryRepositories()
+	def := models.TagDefinition{Tag: "#ginger", Aliases: []string{"#adrak"}}
+	if err := repos.Taxonomy.Put(context.Background(), &def); err != nil {
+		t.Fatal(err)
+	}
+	return NewStoreHandlers(repos, nil, nil), repos
+}
+
+func TestCanonicalTag(t *testing.T) {
+	h, _ := newTaxonomyHandlers(t)
+	for _, tc := range []struct{ in, want string }{
+		{"Ginger", "#ginger"},
+		{"#GINGER", "#ginger"},
+		{"adrak", "#ginger"},
+		{"#Adrak!", "#ginger"},
+		// Not in the taxonomy: still looked up in stored form.
+		{"Turmeric", "#turmeric"},
+	} {
+		got, err := h.canonicalTag(context.Background(), tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("canonicalTag(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+	for _, in := range []string{"", "#", "#a", "123"} {
+		if _, err := h.canonicalTag(context.Background(), in); err != errInvalidTag {
+			t.Errorf("canonicalTag(%q) err = %v, want errInvalidTag", in, err)
+		}
+	}
+}
+
+func TestRetagPosts(t *testing.T) {
+	ctx := context.Background()
+	h, repos := newTaxonomyHandlers(t)
+	posts := []*models.Post{
+		{UserID: "u", Tags: []string{"#Adrak,", "#cooking"}, CreatedAt: time.Now()},
+		{UserID: "u", Tags: []string{"#ginger"}, CreatedAt: time.Now()},
+	}
+	for _, p := range posts {
+		if err := repos.Posts.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changed, err := h.RetagPosts(ctx)
+	if err != nil || changed != 1 {
+		t.Fatalf("RetagPosts = %d, %v; want 1 changed", changed, err)
+	}
+	got, _ := repos.Posts.FindByID(ctx, posts[0].ID.Hex())
+	if !slices.Equal(got.Tags, []string{"#ginger"}) {
+		t.Fatalf("tags = %v, want [#ginger]", got.Tags)
+	}
+}
+
+// A feed filter typed as a reader would finds posts stored under the
+// canonical tag.
+func TestFeedTagFilter(t *testing.T) {
+	ctx := context.Background()
+	h, repos := newTaxonomyHandlers(t)
+	post := models.Post{UserID: "u", Tags: []string{"#ginger"}, CreatedAt: time.Now()}
+	if err := repos.Posts.Create(ctx, &post); err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []string{"Ginger", "ginger!", "#GINGER", "adrak"} {
+		tag, err := h.canonicalTag(ctx, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := repos.Posts.Feed(ctx, store.FeedQuery{Tag: tag})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Posts) != 1 || page.Posts[0].ID != post.ID {
+			t.Errorf("?tag=%s found %d posts, want the #ginger post", in, len(page.Posts))
+		}
+	}
+}
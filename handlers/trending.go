@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// Trending tags are computed by a background job (RefreshTrendingJob) and
+// read from the stored snapshot, so requests never run the aggregation.
+// A tag trends when it is busy now compared with the window before: its
+// activity a and the previous window's p give a score of a*(a+1)/(p+1),
+// which is about a for steady tags, more for rising ones and less for
+// fading ones.
+
+const (
+	// TrendingSchedule is the cron schedule main registers the job with.
+	TrendingSchedule = "*/10 * * * *"
+
+	trendingSize = 20
+	// minTrendingAuthors keeps one prolific poster from making a tag trend.
+	minTrendingAuthors = 2
+)
+
+type trendWindow struct {
+	name   string
+	length time.Duration
+}
+
+var trendWindows = []trendWindow{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+func findTrendWindow(name string) (trendWindow, bool) {
+	for _, w := range trendWindows {
+		if w.name == name {
+			return w, true
+		}
+	}
+	return trendWindow{}, false
+}
+
+// tagActivity weighs posts above the engagement they drew.
+func tagActivity(a models.TagActivity) float64 {
+	return float64(a.Posts) + 0.25*float64(a.Likes) + 0.5*float64(a.Comments)
+}
+
+// RefreshTrending recomputes and stores the trending tags for every window
+// as of now.
+func (h *StoreHandlers) RefreshTrending(ctx context.Context, now time.Time) error {
+	for _, w := range trendWindows {
+		current, err := h.posts.TagActivity(ctx, now.Add(-w.length), now)
+		if err != nil {
+			return fmt.Errorf("trending %s: %w", w.name, err)
+		}
+		previous, err := h.posts.TagActivity(ctx, now.Add(-2*w.length), now.Add(-w.length))
+		if err != nil {
+			return fmt.Errorf("trending %s: %w", w.name, err)
+		}
+		snap := models.TrendingSnapshot{Window: w.name, Tags: rankTrending(current, previous), ComputedAt: now}
+		if err := h.trends.Save(ctx, &snap); err != nil {
+			return fmt.Errorf("trending %s: %w", w.name, err)
+		}
+	}
+	return nil
+}
+
+// rankTrending scores the current window's tags against the previous one
+// and returns the top trendingSize, best first.
+func rankTrending(current, previous []models.TagActivity) []models.TrendingTag {
+	before := map[string]float64{}
+	for _, a := range previous {
+		before[a.Tag] = tagActivity(a)
+	}
+	tags := []models.TrendingTag{}
+	for _, a := range current {
+		if a.Authors < minTrendingAuthors {
+			continue
+		}
+		act, prev := tagActivity(a), before[a.Tag]
+		tags = append(tags, models.TrendingTag{TagActivity: a, Previous: prev, Score: act * (act + 1) / (prev + 1)})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Score != tags[j].Score {
+			return tags[i].Score > tags[j].Score
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	if len(tags) > trendingSize {
+		tags = tags[:trendingSize]
+	}
+	return tags
+}
+
+// RefreshTrendingJob is RefreshTrending for the cron scheduler.
+func (h *StoreHandlers) RefreshTrendingJob(ctx *gofr.Context) {
+	if err := h.RefreshTrending(ctx, time.Now()); err != nil {
+		ctx.Logger.Errorf("refreshing trending tags: %v", err)
+	}
+}
+
+// GetTrendingTags returns the latest trending snapshot for ?window= (24h by
+// default). Before the first refresh the list is empty.
+func (h *StoreHandlers) GetTrendingTags(ctx *gofr.Context) (interface{}, error) {
+	name := ctx.Param("window")
+	if name == "" {
+		name = trendWindows[0].name
+	}
+	if _, ok := findTrendWindow(name); !ok {
+		return nil, fmt.Errorf("400: window must be %q or %q", trendWindows[0].name, trendWindows[1].name)
+	}
+	snap, err := h.trends.Latest(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.TrendingSnapshot{Window: name, Tags: []models.TrendingTag{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	if l := ctx.Param("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("400: invalid limit")
+		}
+		if n < len(snap.Tags) {
+			snap.Tags = snap.Tags[:n]
+		}
+	}
+	return snap, nil
+}
+
+// tagWindowStats is a tag's standing in one trending window; Rank is zero
+// when it is not trending there.
+type tagWindowStats struct {
+	Rank int `json:"rank,omitempty"`
+	*models.TrendingTag
+}
+
+// GetTag is a tag's landing page: how many posts carry it, where it stands
+// in each trending window, and a page of its posts in the usual feed order.
+func (h *StoreHandlers) GetTag(ctx *gofr.Context) (interface{}, error) {
+	// Aliases land on their canonical tag's page.
+	tag, err := h.canonicalTag(ctx, ctx.PathParam("tag"))
+	if err != nil {
+		return nil, err
+	}
+	q := store.FeedQuery{Tag: tag, Cursor: ctx.Param("cursor"), Sort: ctx.Param("sort")}
+	if q.Sort != "" && !store.ValidSort(q.Sort) {
+		return nil, fmt.Errorf("400: sort must be %q or %q", store.SortNewest, store.SortMostLiked)
+	}
+	if l := ctx.Param("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("400: invalid limit")
+		}
+		q.Limit = n
+	}
+	page, err := h.posts.Feed(ctx, q)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return nil, fmt.Errorf("400: %v", err)
+		}
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	if viewer, ok := identity(ctx); ok {
+		markLiked(page.Posts, viewer.UserID)
+	}
+	total, err := h.posts.CountByTag(ctx, tag)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	windows := map[string]tagWindowStats{}
+	for _, w := range trendWindows {
+		snap, err := h.trends.Latest(ctx, w.name)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("500: %v", err)
+		}
+		stats := tagWindowStats{}
+		for i := range snap.Tags {
+			if snap.Tags[i].Tag == tag {
+				stats = tagWindowStats{Rank: i + 1, TrendingTag: &snap.Tags[i]}
+				break
+			}
+		}
+		windows[w.name] = stats
+	}
+	return map[string]interface{}{"tag": tag, "total_posts": total, "trending": windows, "posts": page.Posts, "next_cursor": page.NextCursor}, nil
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"finalapp/models"
+	"finalapp/store"
+	"finalapp/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gofr.dev/pkg/gofr"
+)
+
+// Two-factor sign-in. When the password (or external provider) checks out
+// for an account with TOTP enabled, sign-in returns a short-lived challenge
+// token instead of a session; the session is issued once Verify accepts a
+// code from the authenticator app or a recovery code. Coordinators and
+// admins must use two factors: until they enrol, sign-in returns an
+// enrolment challenge that only allows Setup and Enable.
+
+const (
+	totpIssuer        = "Community Care"
+	mfaChallengeTTL   = 10 * time.Minute
+	recoveryCodeCount = 10
+
+	challengeVerify = "verify"
+	challengeEnroll = "enroll"
+)
+
+var (
+	errInvalidCode      = errors.New("invalid authentication code")
+	errInvalidChallenge = errors.New("sign-in challenge expired, please sign in again")
+)
+
+// requiresTwoFactor reports whether accounts with role must use two factors.
+func requiresTwoFactor(role string) bool {
+	return role == models.RoleCoordinator || role == models.RoleAdmin
+}
+
+func twoFactorEnabled(user *models.User) bool {
+	return user.TwoFactor != nil && user.TwoFactor.Enabled
+}
+
+// secondStep returns the response sending user on to the second sign-in
+// step, or nil when the password alone is enough.
+func (t *Tokens) secondStep(user *models.User) (map[string]interface{}, error) {
+	purpose, message := challengeVerify, "Enter the code from your authenticator app"
+	if !twoFactorEnabled(user) {
+		if !requiresTwoFactor(user.Role) {
+			return nil, nil
+		}
+		purpose, message = challengeEnroll, "Two-factor authentication is required for your role; set it up to continue"
+	}
+	tok, err := t.keys.sign(jwt.MapClaims{"mfa_user": user.ID.Hex(), "mfa": purpose, "exp": time.Now().Add(mfaChallengeTTL).Unix()})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"message":            message,
+		"mfa_required":       true,
+		"mfa_setup_required": purpose == challengeEnroll,
+		"mfa_token":          tok,
+	}, nil
+}
+
+// parseChallenge returns the user a challenge token from secondStep was
+// issued to. Challenge tokens carry no user_id, so they are never accepted
+// as access tokens.
+func (t *Tokens) parseChallenge(tok, purpose string) (string, error) {
+	if tok == "" {
+		return "", errInvalidChallenge
+	}
+	parsed, err := jwt.Parse(tok, t.keys.keyFunc)
+	if err != nil || !parsed.Valid {
+		return "", errInvalidChallenge
+	}
+	claims, _ := parsed.Claims.(jwt.MapClaims)
+	uid, _ := claims["mfa_user"].(string)
+	if got, _ := claims["mfa"].(string); uid == "" || got != purpose {
+		return "", errInvalidChallenge
+	}
+	return uid, nil
+}
+
+// TwoFactor serves TOTP enrolment and the second sign-in step.
+type TwoFactor struct {
+	accounts *Accounts
+	tokens   *Tokens
+	users    store.UserRepository
+}
+
+func NewTwoFactor(repos store.Repositories, accounts *Accounts, tokens *Tokens) *TwoFactor {
+	return &TwoFactor{accounts: accounts, tokens: tokens, users: repos.Users}
+}
+
+// Verify completes a sign-in with the challenge token and a TOTP or
+// recovery code. Wrong codes count as failed sign-ins.
+func (tf *TwoFactor) Verify(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	uid, err := tf.tokens.parseChallenge(req.MFAToken, challengeVerify)
+	if err != nil {
+		return nil, fmt.Errorf("401: %v", err)
+	}
+	user, err := tf.users.FindByID(ctx, uid)
+	if err != nil || !twoFactorEnabled(user) {
+		return nil, fmt.Errorf("401: %v", errInvalidChallenge)
+	}
+	if err := tf.checkCode(ctx, user, req.Code); err != nil {
+		return nil, codeError(err)
+	}
+	return tf.session(ctx, user)
+}
+
+// Status reports whether the caller has two factors enabled, whether their
+// role requires it and how many recovery codes are left.
+func (tf *TwoFactor) Status(ctx *gofr.Context) (interface{}, error) {
+	user, err := tf.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	left := 0
+	if twoFactorEnabled(user) {
+		left = len(user.TwoFactor.RecoveryCodes)
+	}
+	return map[string]interface{}{"enabled": twoFactorEnabled(user), "required": requiresTwoFactor(user.Role), "recovery_codes_left": left}, nil
+}
+
+// Setup starts enrolment for a signed-in user, or for one holding an
+// enrolment challenge, and returns the secret and the otpauth:// URI to show
+// as a QR code. Nothing changes at sign-in until Enable confirms a code.
+func (tf *TwoFactor) Setup(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	_ = ctx.Bind(&req)
+	user, err := tf.enrollee(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactorEnabled(user) {
+		return nil, fmt.Errorf("409: two-factor authentication is already enabled")
+	}
+	secret := totp.GenerateSecret()
+	if err := tf.users.SetTwoFactor(ctx, user.ID.Hex(), &models.TwoFactor{Secret: secret}); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"secret": secret, "otpauth_uri": totp.URI(totpIssuer, user.Email, secret)}, nil
+}
+
+// Enable confirms enrolment with a code from the new secret and returns the
+// recovery codes, which are shown only once. Completing an enrolment
+// challenge also signs the user in.
+func (tf *TwoFactor) Enable(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	user, err := tf.enrollee(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactorEnabled(user) {
+		return nil, fmt.Errorf("409: two-factor authentication is already enabled")
+	}
+	if user.TwoFactor == nil {
+		return nil, fmt.Errorf("400: start setup first")
+	}
+	step, ok := totp.Validate(user.TwoFactor.Secret, req.Code, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("400: code does not match, check your device's clock and try again")
+	}
+	codes, hashes := newRecoveryCodes()
+	enrolment := models.TwoFactor{Secret: user.TwoFactor.Secret, Enabled: true, RecoveryCodes: hashes, LastStep: step}
+	if err := tf.users.SetTwoFactor(ctx, user.ID.Hex(), &enrolment); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	resp := map[string]interface{}{"message": "Two-factor authentication enabled", "recovery_codes": codes}
+	if req.MFAToken != "" {
+		pair, err := tf.tokens.issue(ctx, user)
+		if err != nil {
+			return nil, fmt.Errorf("500: %v", err)
+		}
+		resp["user_id"], resp["token"], resp["refresh_token"], resp["expires_in"] = user.ID.Hex(), pair.AccessToken, pair.RefreshToken, pair.ExpiresIn
+	}
+	return resp, nil
+}
+
+// Disable turns two factors off after checking a current code. Roles that
+// require two factors cannot turn them off.
+func (tf *TwoFactor) Disable(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	user, err := tf.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if requiresTwoFactor(user.Role) {
+		return nil, fmt.Errorf("403: two-factor authentication is required for role %q", user.Role)
+	}
+	if !twoFactorEnabled(user) {
+		return nil, fmt.Errorf("409: two-factor authentication is not enabled")
+	}
+	if err := tf.checkCode(ctx, user, req.Code); err != nil {
+		return nil, codeError(err)
+	}
+	if err := tf.users.SetTwoFactor(ctx, user.ID.Hex(), nil); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"message": "Two-factor authentication disabled"}, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a
+// current code.
+func (tf *TwoFactor) RegenerateRecoveryCodes(ctx *gofr.Context) (interface{}, error) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := ctx.Bind(&req); err != nil {
+		return nil, fmt.Errorf("400: %v", err)
+	}
+	user, err := tf.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !twoFactorEnabled(user) {
+		return nil, fmt.Errorf("409: two-factor authentication is not enabled")
+	}
+	if err := tf.checkCode(ctx, user, req.Code); err != nil {
+		return nil, codeError(err)
+	}
+	// Re-read so the step just used by checkCode is kept.
+	user, err = tf.users.FindByID(ctx, user.ID.Hex())
+	if err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	codes, hashes := newRecoveryCodes()
+	enrolment := *user.TwoFactor
+	enrolment.RecoveryCodes = hashes
+	if err := tf.users.SetTwoFactor(ctx, user.ID.Hex(), &enrolment); err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"message": "Recovery codes replaced", "recovery_codes": codes}, nil
+}
+
+// checkCode accepts an unused TOTP code or recovery code for user, behind
+// the sign-in throttle.
+func (tf *TwoFactor) checkCode(ctx *gofr.Context, user *models.User, code string) error {
+	keys, err := tf.accounts.checkThrottle(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	ok := false
+	if step, valid := totp.Validate(user.TwoFactor.Secret, code, time.Now()); valid {
+		// A replayed code matches but was already used.
+		ok, err = tf.users.UseTOTPStep(ctx, user.ID.Hex(), step)
+	} else if rc := normalizeRecoveryCode(code); rc != "" {
+		ok, err = tf.users.UseRecoveryCode(ctx, user.ID.Hex(), hashToken(rc))
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		tf.accounts.recordFailure(ctx, user.Email, keys)
+		return errInvalidCode
+	}
+	tf.accounts.clearFailures(ctx, user.Email)
+	return nil
+}
+
+func (tf *TwoFactor) session(ctx *gofr.Context, user *models.User) (interface{}, error) {
+	pair, err := tf.tokens.issue(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("500: %v", err)
+	}
+	return map[string]interface{}{"user_id": user.ID.Hex(), "token": pair.AccessToken, "refresh_token": pair.RefreshToken, "expires_in": pair.ExpiresIn}, nil
+}
+
+// caller loads the signed-in user; delegated tokens and devices cannot
+// manage two factors.
+func (tf *TwoFactor) caller(ctx *gofr.Context) (*models.User, error) {
+	id, err := ownIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := tf.users.FindByID(ctx, id.UserID)
+	if err != nil {
+		return nil, notFoundOr500(err, "user")
+	}
+	return user, nil
+}
+
+// enrollee is the user holding the enrolment challenge, if one is given,
+// otherwise the signed-in user.
+func (tf *TwoFactor) enrollee(ctx *gofr.Context, mfaToken string) (*models.User, error) {
+	if mfaToken == "" {
+		return tf.caller(ctx)
+	}
+	uid, err := tf.tokens.parseChallenge(mfaToken, challengeEnroll)
+	if err != nil {
+		return nil, fmt.Errorf("401: %v", err)
+	}
+	user, err := tf.users.FindByID(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("401: %v", errInvalidChallenge)
+	}
+	return user, nil
+}
+
+func codeError(err error) error {
+	var locked *lockedError
+	switch {
+	case errors.Is(err, errInvalidCode):
+		return fmt.Errorf("401: %v", err)
+	case errors.As(err, &locked):
+		return fmt.Errorf("429: %v", err)
+	}
+	return fmt.Errorf("500: %v", err)
+}
+
+// newRecoveryCodes returns fresh recovery codes for display and their
+// hashes for storage.
+func newRecoveryCodes() (codes, hashes []string) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		c := strings.ToLower(totp.GenerateSecret()[:10])
+		codes = append(codes, c[:5]+"-"+c[5:])
+		hashes = append(hashes, hashToken(c))
+	}
+	return codes, hashes
+}
+
+// normalizeRecoveryCode strips the dash and spaces users may type, and
+// returns "" for anything that cannot be a recovery code.
+func normalizeRecoveryCode(code string) string {
+	c := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(c) != 10 {
+		return ""
+	}
+	return c
+}
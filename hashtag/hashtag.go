@@ -0,0 +1,115 @@
+// Package hashtag cleans up post tags: it normalizes spelling, folds
+// aliases onto canonical tags and, once a taxonomy is curated, keeps only
+// tags that belong to it.
+package hashtag
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"finalapp/models"
+)
+
+// MaxPerPost is how many tags a post keeps; AI tagging is asked for "many"
+// and the first ones are the most relevant.
+const MaxPerPost = 8
+
+const minLen = 2
+
+// Clean returns tag in the stored form: "#" followed by its letters and
+// digits, lower-cased. Anything shorter than two characters, or made of
+// digits only, comes back empty.
+func Clean(tag string) string {
+	var b strings.Builder
+	digits := true
+	for _, r := range strings.ToLower(tag) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' {
+			b.WriteRune(r)
+			digits = digits && unicode.IsDigit(r)
+		}
+	}
+	if utf8.RuneCountInString(b.String()) < minLen || digits {
+		return ""
+	}
+	return "#" + b.String()
+}
+
+// Normalize cleans each tag and drops empties and duplicates, keeping the
+// first max in order. A negative max keeps them all.
+func Normalize(raw []string, max int) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, t := range raw {
+		if len(out) == max {
+			break
+		}
+		if t = Clean(t); t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Taxonomy is the curated set of canonical tags and their aliases. The zero
+// value (or one built from no definitions) is empty and lets every cleaned
+// tag through.
+type Taxonomy struct {
+	canonical map[string]string // cleaned tag or alias -> canonical tag
+}
+
+// NewTaxonomy indexes the definitions. Tags and aliases are cleaned, so a
+// definition may spell them loosely.
+func NewTaxonomy(defs []models.TagDefinition) *Taxonomy {
+	t := &Taxonomy{canonical: map[string]string{}}
+	for _, d := range defs {
+		tag := Clean(d.Tag)
+		if tag == "" {
+			continue
+		}
+		t.canonical[tag] = tag
+		for _, a := range d.Aliases {
+			if a = Clean(a); a != "" {
+				t.canonical[a] = tag
+			}
+		}
+	}
+	return t
+}
+
+// Empty reports whether no canonical tags are defined.
+func (t *Taxonomy) Empty() bool {
+	return t == nil || len(t.canonical) == 0
+}
+
+// Canonical maps tag, or one of its aliases, to the canonical tag. With an
+// empty taxonomy every cleaned tag is its own canonical form.
+func (t *Taxonomy) Canonical(tag string) (string, bool) {
+	tag = Clean(tag)
+	if tag == "" {
+		return "", false
+	}
+	if t.Empty() {
+		return tag, true
+	}
+	c, ok := t.canonical[tag]
+	return c, ok
+}
+
+// Apply normalizes raw tags and maps them onto the taxonomy, dropping tags
+// it does not know, then keeps at most max (all if max is negative).
+func (t *Taxonomy) Apply(raw []string, max int) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, tag := range raw {
+		if len(out) == max {
+			break
+		}
+		if c, ok := t.Canonical(tag); ok && !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package hashtag
+
+import (
+	"slices"
+	"testing"
+
+	"finalapp/models"
+)
+
+func TestClean(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"ginger", "#ginger"},
+		{"#Ginger", "#ginger"},
+		{"GINGER", "#ginger"},
+		{"#ginger!", "#ginger"},
+		{"ginger,", "#ginger"},
+		{"  #Ginger Tea  ", "#gingertea"},
+		{"#home_remedy", "#home_remedy"},
+		{"#Café", "#café"},
+		{"#covid19", "#covid19"},
+		// Too short or digits only.
+		{"", ""},
+		{"#", ""},
+		{"#a!", ""},
+		{"#2024", ""},
+	} {
+		if got := Clean(tc.in); got != tc.want {
+			t.Errorf("Clean(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  []string
+		max  int
+		want []string
+	}{
+		{"folds duplicates", []string{"#Ginger", "ginger", "#GINGER!", "#tea"}, 8, []string{"#ginger", "#tea"}},
+		{"drops empties", []string{"#", "#1", "#honey"}, 8, []string{"#honey"}},
+		{"keeps the first max", []string{"#a1", "#b2", "#c3", "#d4"}, 2, []string{"#a1", "#b2"}},
+		// Duplicates do not use up the limit.
+		{"max counts distinct tags", []string{"#tea", "#Tea", "#honey"}, 2, []string{"#tea", "#honey"}},
+		{"negative max keeps all", []string{"#a1", "#b2", "#c3"}, -1, []string{"#a1", "#b2", "#c3"}},
+		{"nothing", nil, 8, []string{}},
+	} {
+		if got := Normalize(tc.raw, tc.max); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: Normalize = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTaxonomy(t *testing.T) {
+	tax := NewTaxonomy([]models.TagDefinition{
+		{Tag: "#ginger", Aliases: []string{"#adrak", "Zingiber!"}},
+		{Tag: "Turmeric", Aliases: []string{"#haldi"}},
+	})
+	for _, tc := range []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"#ginger", "#ginger", true},
+		{"#adrak", "#ginger", true},
+		{"ADRAK!", "#ginger", true},
+		{"#zingiber", "#ginger", true},
+		{"#turmeric", "#turmeric", true},
+		{"haldi", "#turmeric", true},
+		// Not in the taxonomy.
+		{"#honey", "", false},
+		{"#", "", false},
+	} {
+		got, ok := tax.Canonical(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Canonical(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+	got := tax.Apply([]string{"#adrak", "#honey", "#Ginger", "#haldi"}, 8)
+	if want := []string{"#ginger", "#turmeric"}; !slices.Equal(got, want) {
+		t.Errorf("Apply = %q, want %q", got, want)
+	}
+	if got := tax.Apply([]string{"#adrak", "#haldi"}, 1); !slices.Equal(got, []string{"#ginger"}) {
+		t.Errorf("Apply with max 1 = %q", got)
+	}
+}
+
+func TestEmptyTaxonomyKeepsCleanedTags(t *testing.T) {
+	for _, tax := range []*Taxonomy{nil, NewTaxonomy(nil)} {
+		if !tax.Empty() {
+			t.Fatal("taxonomy without definitions is not empty")
+		}
+		if got, ok := tax.Canonical("#Honey!"); got != "#honey" || !ok {
+			t.Errorf("Canonical = %q, %v; want #honey", got, ok)
+		}
+		if got := tax.Apply([]string{"#Honey", "honey", "#tea"}, 8); !slices.Equal(got, []string{"#honey", "#tea"}) {
+			t.Errorf("Apply = %q", got)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional email such as verification and reset links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and configures a Mailer. Mail goes through the SMTP relay
+// unless Transport is "log", which writes it to LogFile, or to stdout when
+// that is empty, for local development.
+type Config struct {
+	Transport string `json:"transport"`
+	SMTPHost  string `json:"smtp_host"`
+	SMTPPort  int    `json:"smtp_port"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	From      string `json:"from"`
+	LogFile   string `json:"log_file"`
+}
+
+// New returns the Mailer cfg selects. An SMTP transport without a host is an
+// error rather than a quiet fallback, so a production server that lost its
+// mail settings fails at start-up instead of printing reset links.
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Transport {
+	case "", "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf(`mail: smtp_host required, or set transport to "log" to write mail locally`)
+		}
+		if cfg.From == "" {
+			return nil, fmt.Errorf("mail: from address required for SMTP")
+		}
+		port := cfg.SMTPPort
+		if port == 0 {
+			port = 587
+		}
+		return &SMTP{Host: cfg.SMTPHost, Port: port, Username: cfg.Username, Password: cfg.Password, From: cfg.From}, nil
+	case "log":
+		if cfg.LogFile != "" {
+			return NewFile(cfg.LogFile)
+		}
+		return NewLog(os.Stdout), nil
+	}
+	return nil, fmt.Errorf("mail: unknown transport %q", cfg.Transport)
+}
+
+// SMTP delivers mail through an SMTP relay using PLAIN auth when a username
+// is set.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTP) Send(_ context.Context, msg Message) error {
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, format(m.From, msg))
+}
+
+// Log writes each message to w instead of sending it. It is meant for local
+// development and tests, where the links can be copied from the output.
+type Log struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLog(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+// NewFile appends messages to the file at path.
+func NewFile(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewLog(f), nil
+}
+
+func (m *Log) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.w, "----- %s -----\n%s\n", time.Now().Format(time.RFC3339), format("no-reply@localhost", msg))
+	return err
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
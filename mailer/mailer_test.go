@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		ok   bool
+	}{
+		{"nothing configured", Config{}, false},
+		{"smtp without host", Config{Transport: "smtp", From: "a@example.com"}, false},
+		{"smtp without from", Config{SMTPHost: "mail.example.com"}, false},
+		{"smtp", Config{SMTPHost: "mail.example.com", From: "a@example.com"}, true},
+		{"log", Config{Transport: "log"}, true},
+		{"unknown transport", Config{Transport: "pigeon"}, false},
+	} {
+		m, err := New(tc.cfg)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: New = %T, %v", tc.name, m, err)
+		}
+	}
+	m, err := New(Config{SMTPHost: "mail.example.com", From: "a@example.com"})
+	if err != nil || m.(*SMTP).Port != 587 {
+		t.Fatalf("default port: %+v, %v", m, err)
+	}
+}
+
+func TestLogSend(t *testing.T) {
+	var buf bytes.Buffer
+	msg := Message{To: "ann@example.com", Subject: "Hello", Body: "link"}
+	if err := NewLog(&buf).Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: ann@example.com\r\n", "Subject: Hello\r\n", "\r\n\r\nlink\r\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -3,11 +3,17 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"log"
 	"net"
 	"os"
+	"strings"
+	"time"
 
 	"finalapp/handlers"
+	"finalapp/mailer"
+	"finalapp/oidc"
+	"finalapp/ranking"
 	"finalapp/store"
 
 	"gofr.dev/pkg/gofr"
@@ -29,6 +35,27 @@ type Config struct {
 	CloudName          string `json:"cloudinary_cloud"`
 	CloudAPIKey        string `json:"cloudinary_api_key"`
 	CloudAPISecret     string `json:"cloudinary_api_secret"`
+	NeighbourDemoMode  bool   `json:"neighbour_demo_mode"`
+	PublicURL          string `json:"public_url"`
+	TrustProxyHeaders  bool   `json:"trust_proxy_headers"`
+
+	// JWTKeys and JWTSigningKeyID enable key rotation and RS256/EdDSA
+	// signing. jwt_secret, if set, stays valid as the "default" HS256 key.
+	JWTKeys         []handlers.JWTKeyConfig `json:"jwt_keys"`
+	JWTSigningKeyID string                  `json:"jwt_signing_key_id"`
+
+	// Mail sends verification and password-reset links through SMTP. For
+	// local development, "transport": "log" writes them to mail.log_file
+	// (or stdout) instead.
+	Mail mailer.Config `json:"mail"`
+
+	// OIDCProviders enables "sign in with" external OpenID Connect
+	// providers. redirect_url defaults to public_url + "/".
+	OIDCProviders []oidc.Config `json:"oidc_providers"`
+
+	// FeedRanking weighs the /feed/for-you ranking. Unset weights use
+	// ranking.DefaultWeights.
+	FeedRanking ranking.Weights `json:"feed_ranking"`
 }
 
 func pickFreePort(candidates []string, fallback string) string {
@@ -43,6 +70,10 @@ func pickFreePort(candidates []string, fallback string) string {
 }
 
 func main() {
+	migrateIdentities := flag.Bool("migrate-identities", false, "link Firestore Neighbour users to accounts by email, then exit")
+	retagPosts := flag.Bool("retag-posts", false, "apply the tag taxonomy to the tags of existing posts, then exit")
+	flag.Parse()
+
 	f, err := os.Open("config.json")
 	if err != nil {
 		log.Fatal(err)
@@ -53,12 +84,48 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if err := store.Init(context.TODO(), cfg.MongoURI, cfg.DBName); err != nil {
+	db, err := store.Init(context.TODO(), cfg.MongoURI, cfg.DBName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := store.EnsureIndexes(context.TODO(), db); err != nil {
+		log.Fatal(err)
+	}
+	repos := store.NewMongoRepositories(db)
+	keys, err := handlers.LoadKeySet(cfg.JWTSecret, cfg.JWTKeys, cfg.JWTSigningKeyID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
 		log.Fatal(err)
 	}
+	publicURL := cfg.PublicURL
+	if publicURL == "" {
+		publicURL = "http://localhost:" + cfg.Port
+		if cfg.Port == "" {
+			publicURL += "8000"
+		}
+	}
+	var providers []*oidc.Provider
+	for _, pc := range cfg.OIDCProviders {
+		if pc.RedirectURL == "" {
+			pc.RedirectURL = strings.TrimRight(publicURL, "/") + "/"
+		}
+		p, err := oidc.New(pc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		providers = append(providers, p)
+	}
+	accounts := handlers.NewAccounts(repos, mail, publicURL)
+	tokens := handlers.NewTokens(repos, keys)
+	externalLogin := handlers.NewOIDCLogin(repos, accounts, tokens, providers)
+	twoFactor := handlers.NewTwoFactor(repos, accounts, tokens)
+	community := handlers.NewStoreHandlers(repos, accounts, tokens)
+	neighbour := handlers.NewNeighbourHandlers(repos, accounts, tokens)
 
 	handlers.SetConfig(handlers.ServerConfig{
-		JWTSecret:          cfg.JWTSecret,
 		ElevenLabsKey:      cfg.ElevenKeyBase,
 		GeminiKey:          cfg.GeminiKeyBase,
 		ChatGeminiKey:      cfg.GeminiKeyChat,
@@ -70,11 +137,32 @@ func main() {
 		CloudAPISecret:     cfg.CloudAPISecret,
 		FirestoreProjectID: cfg.FirestoreProjectID,
 		GoogleCredentials:  cfg.GoogleCredentials,
+		NeighbourDemoMode:  cfg.NeighbourDemoMode,
+		TrustProxyHeaders:  cfg.TrustProxyHeaders,
+		FeedRanking:        cfg.FeedRanking,
 	})
 
 	if cfg.GoogleCredentials != "" {
 		_ = os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", cfg.GoogleCredentials)
 	}
+
+	if *migrateIdentities {
+		linked, created, err := accounts.MigrateNeighbours(context.TODO())
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Neighbour identities migrated: %d linked, %d created", linked, created)
+		return
+	}
+	if *retagPosts {
+		changed, err := community.RetagPosts(context.TODO())
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Posts retagged: %d changed", changed)
+		return
+	}
+
 	if cfg.CloudName != "" {
 		_ = os.Setenv("CLOUDINARY_CLOUD_NAME", cfg.CloudName)
 	}
@@ -102,23 +190,90 @@ func main() {
 
 	app := gofr.New()
 	app.AddStaticFiles("/", "./public")
+	app.UseMiddleware(handlers.ClientIP, tokens.Middleware)
+	app.Metrics().NewCounter(handlers.MetricLoginFailures, "Failed sign-in attempts")
+	app.Metrics().NewCounter(handlers.MetricLoginThrottled, "Sign-ins refused during backoff or lockout")
+	app.Metrics().NewCounter(handlers.MetricLoginLockouts, "Accounts and addresses locked after repeated failures")
 
-	app.POST("/signup", handlers.SignUp)
-	app.POST("/login", handlers.Login)
-	app.POST("/posts", handlers.CreatePost)
-	app.GET("/feed", handlers.GetFeed)
-	app.POST("/user/posts", handlers.GetUserPosts)
+	app.POST("/signup", community.SignUp)
+	app.POST("/login", community.Login)
+	app.POST("/auth/refresh", tokens.Refresh)
+	app.POST("/auth/logout", tokens.Logout)
+	app.POST("/auth/verify-email/request", accounts.RequestVerification)
+	app.POST("/auth/verify-email/confirm", accounts.ConfirmVerification)
+	app.POST("/auth/password-reset/request", accounts.RequestPasswordReset)
+	app.POST("/auth/password-reset/confirm", accounts.ConfirmPasswordReset)
+	app.POST("/auth/unlock/confirm", accounts.ConfirmUnlock)
+	app.GET("/auth/oidc/providers", externalLogin.Providers)
+	app.POST("/auth/oidc/{provider}/start", externalLogin.Start)
+	app.POST("/auth/oidc/callback", externalLogin.Callback)
+	app.POST("/auth/2fa/verify", twoFactor.Verify)
+	app.GET("/auth/2fa", twoFactor.Status)
+	app.POST("/auth/2fa/setup", twoFactor.Setup)
+	app.POST("/auth/2fa/enable", twoFactor.Enable)
+	app.POST("/auth/2fa/disable", twoFactor.Disable)
+	app.POST("/auth/2fa/recovery-codes", twoFactor.RegenerateRecoveryCodes)
+	app.GET("/.well-known/jwks.json", keys.JWKS)
+	app.POST("/posts", community.CreatePost)
+	app.PUT("/posts/{id}", community.UpdatePost)
+	app.DELETE("/posts/{id}", community.DeletePost)
+	app.GET("/feed", community.GetFeed)
+	app.GET("/feed/for-you", community.GetForYouFeed)
+	app.GET("/feed/following", community.GetFollowingFeed)
+	app.GET("/search", community.Search)
+	app.GET("/tags/trending", community.GetTrendingTags)
+	app.GET("/tags/{tag}", community.GetTag)
+	app.POST("/posts/{id}/like", community.LikePost)
+	app.DELETE("/posts/{id}/like", community.UnlikePost)
+	app.POST("/posts/{id}/comments", community.CreateComment)
+	app.GET("/posts/{id}/comments", community.GetComments)
+	app.PUT("/comments/{id}", community.UpdateComment)
+	app.DELETE("/comments/{id}", community.DeleteComment)
+	app.GET("/user/posts", community.GetUserPosts)
+	app.GET("/profile", community.GetProfile)
+	app.PUT("/profile", community.UpdateProfile)
+	app.GET("/users/{id}", community.GetUser)
+	app.POST("/users/{id}/follow", community.FollowUser)
+	app.DELETE("/users/{id}/follow", community.UnfollowUser)
+	app.GET("/users/{id}/followers", community.GetFollowers)
+	app.GET("/users/{id}/following", community.GetFollowing)
 
-	app.POST("/api/signup", handlers.NeighbourSignUp)
-	app.POST("/api/signin", handlers.NeighbourSignIn)
-	app.POST("/api/upload", handlers.NeighbourUploadAudio)
-	app.GET("/api/helper", handlers.NeighbourGetHelperRequests)
-	app.POST("/api/assignRequest", handlers.NeighbourAssignRequest)
-	app.POST("/api/eld-people/confirm", handlers.NeighbourConfirmRequest)
-	app.POST("/api/reward/claim", handlers.NeighbourClaimReward)
+	app.POST("/api/signup", neighbour.NeighbourSignUp)
+	app.POST("/api/signin", neighbour.NeighbourSignIn)
+	app.POST("/api/join", neighbour.NeighbourJoin)
+	app.POST("/api/upload", handlers.RequirePermission(handlers.PermCreateRequest, neighbour.NeighbourUploadAudio))
+	app.GET("/api/helper", handlers.RequirePermission(handlers.PermViewRequests, neighbour.NeighbourGetHelperRequests))
+	app.POST("/api/assignRequest", handlers.RequirePermission(handlers.PermAssignRequest, neighbour.NeighbourAssignRequest))
+	app.POST("/api/eld-people/confirm", handlers.RequirePermission(handlers.PermConfirmRequest, neighbour.NeighbourConfirmRequest))
+	app.POST("/api/reward/claim", handlers.RequirePermission(handlers.PermClaimReward, neighbour.NeighbourClaimReward))
+	app.POST("/api/admin/roles", handlers.RequirePermission(handlers.PermManageRoles, neighbour.SetUserRole))
+	app.POST("/api/admin/unlock", handlers.RequirePermission(handlers.PermManageAccounts, accounts.Unlock))
+	app.GET("/api/eld-people/requests", handlers.RequirePermission(handlers.PermViewHistory, neighbour.NeighbourRequestHistory))
+	app.POST("/api/delegations", handlers.RequirePermission(handlers.PermDelegate, neighbour.GrantDelegation))
+	app.DELETE("/api/delegations/{caregiverId}", handlers.RequirePermission(handlers.PermDelegate, neighbour.RevokeDelegation))
+	app.GET("/api/delegations", neighbour.ListDelegations)
+	app.POST("/api/delegations/act", neighbour.ActFor)
+	app.GET("/api/delegations/audit", handlers.RequirePermission(handlers.PermDelegate, neighbour.GetAuditTrail))
+	app.POST("/api/admin/devices", handlers.RequirePermission(handlers.PermManageDevices, neighbour.RegisterDevice))
+	app.GET("/api/admin/devices", handlers.RequirePermission(handlers.PermManageDevices, neighbour.ListDevices))
+	app.PUT("/api/admin/devices/{id}", handlers.RequirePermission(handlers.PermManageDevices, neighbour.UpdateDevice))
+	app.POST("/api/admin/devices/{id}/rotate", handlers.RequirePermission(handlers.PermManageDevices, neighbour.RotateDeviceKey))
+	app.DELETE("/api/admin/devices/{id}", handlers.RequirePermission(handlers.PermManageDevices, neighbour.RevokeDevice))
+	app.GET("/api/admin/tags", handlers.RequirePermission(handlers.PermManageTags, community.ListTagDefinitions))
+	app.PUT("/api/admin/tags/{tag}", handlers.RequirePermission(handlers.PermManageTags, community.PutTagDefinition))
+	app.DELETE("/api/admin/tags/{tag}", handlers.RequirePermission(handlers.PermManageTags, community.DeleteTagDefinition))
 
 	app.POST("/api/audio-chat", handlers.AudioChatHandler)
 
+	// Trending tags are served from a snapshot; fill it now rather than
+	// waiting for the first scheduled run.
+	app.AddCronJob(handlers.TrendingSchedule, "trending-tags", community.RefreshTrendingJob)
+	go func() {
+		if err := community.RefreshTrending(context.Background(), time.Now()); err != nil {
+			log.Printf("refreshing trending tags: %v", err)
+		}
+	}()
+
 	log.Printf("Server running on port %s (metrics %s)", httpPort, metricsPort)
 	app.Run()
 }
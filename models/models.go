@@ -6,6 +6,36 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Post sections. The frontend's feed tabs and upload form use these values.
+const (
+	SectionRemedies   = "remedies"
+	SectionExperience = "experience"
+)
+
+// ValidSection reports whether s is one of the known post sections.
+func ValidSection(s string) bool {
+	return s == SectionRemedies || s == SectionExperience
+}
+
+// Neighbour network roles. Elders and helpers sign themselves up;
+// coordinators and admins are granted by an admin.
+const (
+	RoleElder       = "elder"
+	RoleHelper      = "helper"
+	RoleCoordinator = "coordinator"
+	RoleAdmin       = "admin"
+)
+
+// ValidNeighbourRole reports whether r is a role a user can sign up for.
+func ValidNeighbourRole(r string) bool {
+	return r == RoleElder || r == RoleHelper
+}
+
+// ValidRole reports whether r is any known role.
+func ValidRole(r string) bool {
+	return ValidNeighbourRole(r) || r == RoleCoordinator || r == RoleAdmin
+}
+
 type User struct {
 	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Email          string             `bson:"email" json:"email"`
@@ -16,20 +46,54 @@ type User struct {
 	ProfilePicture string             `bson:"profile_picture" json:"profile_picture"`
 	LikedPosts     []string           `bson:"liked_posts" json:"liked_posts"`
 	PreferredTags  []string           `bson:"preferred_tags" json:"preferred_tags"`
+	Role           string             `bson:"role,omitempty" json:"role,omitempty"`                 // Neighbour network role, e.g. elder or helper
+	NeighbourID    string             `bson:"neighbour_id,omitempty" json:"neighbour_id,omitempty"` // Linked Firestore users doc
+	EmailVerified  bool               `bson:"email_verified" json:"email_verified"`
+	ExternalLogins []ExternalLogin    `bson:"external_logins,omitempty" json:"-"` // Linked OpenID Connect identities
+	TwoFactor      *TwoFactor         `bson:"two_factor,omitempty" json:"-"`
 	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 }
+
+// TwoFactor is a user's TOTP enrolment. Secret is set by setup and only
+// takes effect once a code from it has been confirmed (Enabled).
+// RecoveryCodes holds the hashes of the unused one-time recovery codes;
+// LastStep is the last TOTP step accepted, so codes cannot be replayed.
+type TwoFactor struct {
+	Secret        string   `bson:"secret"`
+	Enabled       bool     `bson:"enabled"`
+	RecoveryCodes []string `bson:"recovery_codes,omitempty"`
+	LastStep      int64    `bson:"last_step"`
+}
+
 type Post struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       string             `bson:"userid" json:"user_id"`     // Changed to match handler usage
+	UserName     string             `bson:"username" json:"user_name"` // Added UserName field
+	Content      string             `bson:"content" json:"content"`    // Added Content field
+	MediaURL     string             `bson:"media_url" json:"media_url"`
+	MediaType    string             `bson:"media_type" json:"media_type"`
+	Section      string             `bson:"section" json:"section"`
+	Tags         []string           `bson:"tags" json:"tags"`
+	Likes        int                `bson:"likes" json:"likes"`
+	LikedBy      []string           `bson:"liked_by" json:"liked_by"`
+	LikedByMe    bool               `bson:"-" json:"liked_by_me"` // Set per viewer, never stored
+	CommentCount int                `bson:"comment_count" json:"comment_count"`
+	DeletedAt    *time.Time         `bson:"deleted_at,omitempty" json:"-"` // Soft delete marker
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Comment is a remark on a post. Replies set ParentID to a top-level comment;
+// threads are one level deep.
+type Comment struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    string             `bson:"userid" json:"user_id"`     // Changed to match handler usage
-	UserName  string             `bson:"username" json:"user_name"` // Added UserName field
-	Content   string             `bson:"content" json:"content"`    // Added Content field
-	MediaURL  string             `bson:"media_url" json:"media_url"`
-	MediaType string             `bson:"media_type" json:"media_type"`
-	Section   string             `bson:"section" json:"section"`
-	Tags      []string           `bson:"tags" json:"tags"`
-	Likes     int                `bson:"likes" json:"likes"`
-	LikedBy   []string           `bson:"liked_by" json:"liked_by"`
+	PostID    string             `bson:"post_id" json:"post_id"`
+	ParentID  string             `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	UserID    string             `bson:"userid" json:"user_id"`
+	UserName  string             `bson:"username" json:"user_name"`
+	Content   string             `bson:"content" json:"content"`
+	Replies   []Comment          `bson:"-" json:"replies,omitempty"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
 }
@@ -40,3 +104,146 @@ type ProfileUpdateRequest struct {
 	Location       string `json:"location"`
 	ProfilePicture string `json:"profile_picture"`
 }
+
+// RefreshToken is a server-side record of an issued refresh token. Only the
+// SHA-256 of the token is stored. Every rotation stays in the same family, so
+// a reused (already rotated) token can revoke the whole chain.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Hash      string             `bson:"hash" json:"-"`
+	FamilyID  string             `bson:"family_id" json:"family_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Purposes of an AccountToken.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	PurposeUnlockAccount = "unlock_account"
+)
+
+// AccountToken is a single-use, expiring token mailed to a user to verify
+// their email address or reset their password. Only its hash is stored.
+type AccountToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Hash      string             `bson:"hash" json:"-"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Purpose   string             `bson:"purpose" json:"purpose"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// LoginAttempt tracks recent failed sign-ins for one key, either an account
+// ("account:<email>") or a client address ("ip:<addr>").
+type LoginAttempt struct {
+	Key         string     `bson:"_id" json:"key"`
+	Failures    int        `bson:"failures" json:"failures"`
+	LastFailure time.Time  `bson:"last_failure" json:"last_failure"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	ExpiresAt   time.Time  `bson:"expires_at" json:"-"`
+}
+
+// Delegation lets a caregiver act for an elder on the Neighbour network,
+// limited to Scopes (permission names such as "request:create").
+type Delegation struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ElderID     string             `bson:"elder_id" json:"elderId"`
+	CaregiverID string             `bson:"caregiver_id" json:"caregiverId"`
+	Scopes      []string           `bson:"scopes" json:"scopes"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
+	RevokedAt   *time.Time         `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+}
+
+// AuditEntry records an action taken for an elder by someone else, and
+// changes to who may act for them.
+type AuditEntry struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ActorID string             `bson:"actor_id" json:"actorId"`
+	ElderID string             `bson:"elder_id" json:"elderId"`
+	Action  string             `bson:"action" json:"action"`
+	Target  string             `bson:"target,omitempty" json:"target,omitempty"`
+	At      time.Time          `bson:"at" json:"at"`
+}
+
+// Device is a shared kiosk or voice device that signs in with an API key
+// instead of a password. It may only act for the elders in ElderIDs, within
+// Scopes. Only a hash of the key is stored.
+type Device struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	ElderIDs   []string           `bson:"elder_ids" json:"elderIds"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	KeyPrefix  string             `bson:"key_prefix" json:"keyPrefix"` // Shown so admins can tell keys apart
+	CreatedBy  string             `bson:"created_by" json:"createdBy"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updatedAt"`
+	LastSeenAt *time.Time         `bson:"last_seen_at,omitempty" json:"lastSeenAt,omitempty"`
+	LastSeenIP string             `bson:"last_seen_ip,omitempty" json:"lastSeenIp,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+}
+
+// ExternalLogin links an account to an identity at an OpenID Connect issuer.
+type ExternalLogin struct {
+	Issuer  string `bson:"issuer" json:"issuer"`
+	Subject string `bson:"subject" json:"subject"`
+}
+
+// OIDCSession holds what an OpenID Connect sign-in needs between sending the
+// browser to the provider and its return. It is looked up by the state hash.
+type OIDCSession struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	StateHash string             `bson:"state_hash" json:"-"`
+	Provider  string             `bson:"provider" json:"provider"`
+	Nonce     string             `bson:"nonce" json:"-"`
+	Verifier  string             `bson:"verifier" json:"-"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+// TagActivity is how much a tag was used in one time window.
+type TagActivity struct {
+	Tag      string `bson:"_id" json:"tag"`
+	Posts    int    `bson:"posts" json:"posts"`
+	Authors  int    `bson:"authors" json:"authors"`
+	Likes    int    `bson:"likes" json:"likes"`
+	Comments int    `bson:"comments" json:"comments"`
+}
+
+// TrendingTag is a tag's activity in a trending window, the activity in the
+// window before it, and the resulting trend score.
+type TrendingTag struct {
+	TagActivity `bson:",inline"`
+	Previous    float64 `bson:"previous" json:"previous_activity"`
+	Score       float64 `bson:"score" json:"score"`
+}
+
+// TrendingSnapshot is the trending tags for one window ("24h", "7d") as of
+// ComputedAt, best first. A background job replaces it periodically.
+type TrendingSnapshot struct {
+	Window     string        `bson:"_id" json:"window"`
+	Tags       []TrendingTag `bson:"tags" json:"tags"`
+	ComputedAt time.Time     `bson:"computed_at" json:"computed_at"`
+}
+
+// TagDefinition is a canonical tag in the admin-curated taxonomy. Posts
+// tagged with any of its aliases are tagged with Tag instead.
+type TagDefinition struct {
+	Tag         string    `bson:"_id" json:"tag"`
+	Aliases     []string  `bson:"aliases" json:"aliases"`
+	Description string    `bson:"description,omitempty" json:"description,omitempty"`
+	UpdatedAt   time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Follow records that FollowerID follows FolloweeID; both are user IDs.
+type Follow struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FollowerID string             `bson:"follower_id" json:"follower_id"`
+	FolloweeID string             `bson:"followee_id" json:"followee_id"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,208 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testIssuer is a minimal provider in the manner of cmd/devissuer. The token
+// endpoint checks the PKCE verifier against challenge and signs claims.
+type testIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	claims    jwt.MapClaims
+	// discoveryIssuer overrides the issuer in the discovery document.
+	discoveryIssuer string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	is := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		iss := is.URL
+		if is.discoveryIssuer != "" {
+			iss = is.discoveryIssuer
+		}
+		writeTestJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 iss,
+			"authorization_endpoint": is.URL + "/authorize",
+			"token_endpoint":         is.URL + "/token",
+			"jwks_uri":               is.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		if id, secret, _ := r.BasicAuth(); id != "client" || secret != "secret" {
+			writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if r.Form.Get("code") != "code" || base64.RawURLEncoding.EncodeToString(sum[:]) != is.challenge {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]string{"id_token": is.sign(t, is.claims)})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		pub := is.key.PublicKey
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	})
+	is.Server = httptest.NewServer(mux)
+	t.Cleanup(is.Close)
+	return is
+}
+
+// validClaims are claims the provider under test accepts, for nonce "n".
+func (is *testIssuer) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss": is.URL, "aud": "client", "sub": "user-1", "nonce": "n",
+		"exp": time.Now().Add(5 * time.Minute).Unix(), "iat": time.Now().Unix(),
+		"email": "ann@example.com", "email_verified": "true", "name": "Ann",
+	}
+}
+
+func (is *testIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = "k1"
+	raw, err := tok.SignedString(is.key)
+	if err != nil {
+		t.Error(err)
+	}
+	return raw
+}
+
+func (is *testIssuer) provider(t *testing.T) *Provider {
+	t.Helper()
+	p, err := New(Config{Name: "test", Issuer: is.URL, ClientID: "client", ClientSecret: "secret", RedirectURL: "http://app/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	is := newTestIssuer(t)
+	raw, err := is.provider(t).AuthCodeURL(context.Background(), "st", "n", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !strings.HasPrefix(raw, is.URL+"/authorize?") {
+		t.Fatalf("url = %q, %v", raw, err)
+	}
+	sum := sha256.Sum256([]byte("verifier"))
+	q := u.Query()
+	for k, want := range map[string]string{
+		"state": "st", "nonce": "n", "client_id": "client", "response_type": "code",
+		"redirect_uri":          "http://app/callback",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	is := newTestIssuer(t)
+	is.discoveryIssuer = "https://elsewhere.example"
+	if _, err := is.provider(t).AuthCodeURL(context.Background(), "st", "n", "v"); err == nil {
+		t.Fatal("discovery with a different issuer accepted")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	is := newTestIssuer(t)
+	p := is.provider(t)
+	sum := sha256.Sum256([]byte("verifier"))
+	is.challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	is.claims = is.validClaims()
+
+	c, err := p.Exchange(context.Background(), "code", "verifier", "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Claims{Issuer: is.URL, Subject: "user-1", Email: "ann@example.com", EmailVerified: true, Name: "Ann"}
+	if *c != want {
+		t.Fatalf("claims = %+v, want %+v", *c, want)
+	}
+	if _, err := p.Exchange(context.Background(), "code", "other verifier", "n"); err == nil {
+		t.Fatal("exchange with the wrong PKCE verifier succeeded")
+	}
+	if _, err := p.Exchange(context.Background(), "code", "verifier", "other nonce"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("nonce mismatch: err = %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	is := newTestIssuer(t)
+	p := is.provider(t)
+	for _, tc := range []struct {
+		name   string
+		change func(jwt.MapClaims)
+	}{
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://elsewhere.example" }},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"no expiry", func(c jwt.MapClaims) { delete(c, "exp") }},
+		{"no subject", func(c jwt.MapClaims) { delete(c, "sub") }},
+		{"missing nonce", func(c jwt.MapClaims) { delete(c, "nonce") }},
+	} {
+		claims := is.validClaims()
+		tc.change(claims)
+		if _, err := p.Verify(context.Background(), is.sign(t, claims), "n"); err == nil {
+			t.Errorf("%s: token accepted", tc.name)
+		}
+	}
+
+	// Signed by a key the provider does not publish.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, is.validClaims())
+	tok.Header["kid"] = "k1"
+	raw, _ := tok.SignedString(other)
+	if _, err := p.Verify(context.Background(), raw, "n"); err == nil {
+		t.Error("token signed with another key accepted")
+	}
+	// HMAC with the public modulus as secret must not pass as RS256.
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, is.validClaims())
+	raw, _ = hs.SignedString(is.key.PublicKey.N.Bytes())
+	if _, err := p.Verify(context.Background(), raw, "n"); err == nil {
+		t.Error("HS256 token accepted")
+	}
+}
@@ -17,10 +17,19 @@ function initializeApp() {
     document.getElementById("loading").style.display = "none";
     console.log("[v0] Loading screen hidden");
   }, 1000);
+  // Links from verification and password-reset emails
+  handleEmailLinks();
+  // Returning from an external sign-in provider
+  const oidcReturn = handleOIDCCallback();
+  loadOIDCProviders();
   // Check if user is logged in
   const token = localStorage.getItem("token");
-  if (token) {
+  if (oidcReturn) {
+    showHomepage();
+  } else if (token) {
     console.log("[v0] Token found, attempting auto-login");
+    // The stored access token may have expired; rotate it right away.
+    refreshSession();
     showMainApp();
     loadUserProfile();
     loadFeed();
@@ -64,6 +73,9 @@ function setupEventListeners() {
   document
     .getElementById("signupSubmit")
     ?.addEventListener("click", handleSignup);
+  document
+    .getElementById("forgotPassword")
+    ?.addEventListener("click", handleForgotPassword);
   // App navigation
   document.querySelectorAll(".nav-tab").forEach((tab) => {
     tab.addEventListener("click", (e) => {
@@ -78,6 +90,10 @@ function setupEventListeners() {
   document.querySelectorAll(".filter-btn").forEach((btn) => {
     btn.addEventListener("click", (e) => filterFeed(e.target.dataset.section));
   });
+  document.getElementById("searchBtn")?.addEventListener("click", searchPosts);
+  document.getElementById("searchInput")?.addEventListener("keydown", (e) => {
+    if (e.key === "Enter") searchPosts();
+  });
   // Upload form
   document
     .getElementById("uploadSubmit")
@@ -130,8 +146,11 @@ function setupEventListeners() {
 async function triggerNeighbourHelp() {
   try {
     showToast("Creating help request…", "info");
-    const params = new URLSearchParams({ elderLat: "40.7128", elderLng: "-74.0060", elderId: "demo-elder" });
-    const res = await fetch(`/api/upload?${params.toString()}`, { method: "POST" });
+    const params = new URLSearchParams({ elderLat: "40.7128", elderLng: "-74.0060" });
+    const res = await fetch(`/api/upload?${params.toString()}`, {
+      method: "POST",
+      headers: { Authorization: `Bearer ${localStorage.getItem("token")}` },
+    });
     const data = await res.json();
     if (res.ok) {
       showToast("Help request created!", "success");
@@ -241,15 +260,20 @@ async function handleLogin() {
       "[v0] Response headers:",
       Object.fromEntries(response.headers.entries())
     );
-    const data = await response.json();
+    let data = await response.json();
     console.log("[v0] Login response data:", data);
+    if (response.ok && (data.data || data).mfa_required) {
+      const session = await completeSecondStep(data.data || data);
+      if (!session) return;
+      data = session;
+    }
     const token = data.data ? data.data.token : data.token;
     const userId = data.data ? data.data.user_id : data.user_id;
     console.log("[v0] Extracted token:", token);
     console.log("[v0] Extracted user_id:", userId);
     console.log("[v0] Response has token:", !!token);
     if (response.ok && token) {
-      localStorage.setItem("token", token);
+      storeSession(data.data || data);
       currentUser = { id: userId, email };
       console.log("[v0] Login successful, user:", currentUser);
       hideAuthModal();
@@ -304,7 +328,7 @@ async function handleSignup() {
     const token = data.data ? data.data.token : data.token;
     const userId = data.data ? data.data.user_id : data.user_id;
     if (response.ok && token) {
-      localStorage.setItem("token", token);
+      storeSession(data.data || data);
       currentUser = { id: userId, name, email };
       console.log("[v0] Signup successful, user:", currentUser);
       hideAuthModal();
@@ -328,9 +352,238 @@ async function handleSignup() {
   }
 }
 
+async function handleForgotPassword(e) {
+  e?.preventDefault();
+  const email =
+    document.getElementById("loginEmail").value.trim() ||
+    prompt("Enter your account email");
+  if (!email) return;
+  try {
+    const response = await fetch("/auth/password-reset/request", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ email }),
+    });
+    const data = await response.json();
+    const message = (data.data || data).message || "Check your inbox for a reset link";
+    showToast(message, response.ok ? "success" : "error");
+  } catch (error) {
+    console.error("[v0] Password reset request failed:", error);
+    showToast("Network error. Please try again.", "error");
+  }
+}
+
+// Verification and reset emails link back here with a one-time token.
+async function handleEmailLinks() {
+  const params = new URLSearchParams(window.location.search);
+  const verifyToken = params.get("verify_token");
+  const resetToken = params.get("reset_token");
+  const unlockToken = params.get("unlock_token");
+  if (!verifyToken && !resetToken && !unlockToken) return;
+  window.history.replaceState({}, "", window.location.pathname);
+  let url, body;
+  if (verifyToken) {
+    url = "/auth/verify-email/confirm";
+    body = { token: verifyToken };
+  } else if (unlockToken) {
+    url = "/auth/unlock/confirm";
+    body = { token: unlockToken };
+  } else {
+    const password = prompt("Choose a new password (at least 8 characters)");
+    if (!password) return;
+    url = "/auth/password-reset/confirm";
+    body = { token: resetToken, password };
+  }
+  try {
+    const response = await fetch(url, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(body),
+    });
+    const data = await response.json();
+    const message = (data.data || data).message || (data.error && data.error.message);
+    showToast(message || "Something went wrong", response.ok ? "success" : "error");
+  } catch (error) {
+    console.error("[v0] Email link failed:", error);
+    showToast("Network error. Please try again.", "error");
+  }
+}
+
+// "Sign in with" buttons for the configured OpenID Connect providers.
+async function loadOIDCProviders() {
+  const container = document.getElementById("oidcProviders");
+  if (!container) return;
+  try {
+    const response = await fetch("/auth/oidc/providers");
+    if (!response.ok) return;
+    const data = await response.json();
+    const providers = (data.data || data).providers || [];
+    container.innerHTML = "";
+    providers.forEach((provider) => {
+      const button = document.createElement("button");
+      button.className = "btn btn-secondary btn-full";
+      button.textContent = `Sign in with ${provider.display_name}`;
+      button.addEventListener("click", () => startOIDCLogin(provider.name));
+      container.appendChild(button);
+    });
+  } catch (error) {
+    console.error("[v0] Loading sign-in providers failed:", error);
+  }
+}
+
+async function startOIDCLogin(name) {
+  try {
+    const response = await fetch(`/auth/oidc/${encodeURIComponent(name)}/start`, { method: "POST" });
+    const data = await response.json();
+    const result = data.data || data;
+    if (!response.ok || !result.url) {
+      showToast((data.error && data.error.message) || "Sign-in provider unavailable", "error");
+      return;
+    }
+    // Only a callback carrying this state is completed.
+    sessionStorage.setItem("oidcState", result.state);
+    window.location.href = result.url;
+  } catch (error) {
+    console.error("[v0] Starting external sign-in failed:", error);
+    showToast("Network error. Please try again.", "error");
+  }
+}
+
+// The provider redirects back here with code and state. Returns true when
+// this page load is such a callback.
+function handleOIDCCallback() {
+  const params = new URLSearchParams(window.location.search);
+  const code = params.get("code");
+  const state = params.get("state");
+  if (!code || !state) return false;
+  window.history.replaceState({}, "", window.location.pathname);
+  const expected = sessionStorage.getItem("oidcState");
+  sessionStorage.removeItem("oidcState");
+  if (!expected || expected !== state) {
+    showToast("Sign-in link is not valid, please try again", "error");
+    return false;
+  }
+  completeOIDCLogin(code, state);
+  return true;
+}
+
+async function completeOIDCLogin(code, state) {
+  try {
+    const response = await fetch("/auth/oidc/callback", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ code, state }),
+    });
+    const data = await response.json();
+    let result = data.data || data;
+    if (response.ok && result.mfa_required) {
+      result = await completeSecondStep(result);
+      if (!result) return;
+    }
+    if (response.ok && result.token) {
+      storeSession(result);
+      currentUser = { id: result.user_id };
+      showMainApp();
+      loadUserProfile();
+      loadFeed();
+      showToast("Welcome! 🎉", "success");
+    } else {
+      showToast((data.error && data.error.message) || "Sign-in failed", "error");
+    }
+  } catch (error) {
+    console.error("[v0] External sign-in failed:", error);
+    showToast("Network error. Please try again.", "error");
+  }
+}
+
+// Second sign-in step for accounts with two-factor authentication, or
+// enrolment for roles that require it. Resolves to the session, or null.
+async function completeSecondStep(challenge) {
+  const post = async (url, body) => {
+    const response = await fetch(url, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(body),
+    });
+    const data = await response.json();
+    if (!response.ok) {
+      throw new Error((data.error && data.error.message) || "Verification failed");
+    }
+    return data.data || data;
+  };
+  try {
+    if (challenge.mfa_setup_required) {
+      const setup = await post("/auth/2fa/setup", { mfa_token: challenge.mfa_token });
+      const code = prompt(
+        `${challenge.message}\n\nAdd this account to your authenticator app (scan ${setup.otpauth_uri} as a QR code, or enter the key ${setup.secret}), then enter the 6-digit code it shows:`
+      );
+      if (!code) return null;
+      const enabled = await post("/auth/2fa/enable", { mfa_token: challenge.mfa_token, code });
+      alert(
+        `Save these recovery codes somewhere safe. Each can be used once if you lose your device:\n\n${enabled.recovery_codes.join("\n")}`
+      );
+      return enabled;
+    }
+    const code = prompt(`${challenge.message} (or a recovery code)`);
+    if (!code) return null;
+    return await post("/auth/2fa/verify", { mfa_token: challenge.mfa_token, code });
+  } catch (error) {
+    console.error("[v0] Two-factor step failed:", error);
+    showToast(error.message, "error");
+    return null;
+  }
+}
+
+// Access tokens are short-lived; keep them fresh with the refresh token.
+let refreshTimer = null;
+
+function storeSession(session) {
+  localStorage.setItem("token", session.token);
+  if (session.refresh_token) {
+    localStorage.setItem("refreshToken", session.refresh_token);
+  }
+  scheduleRefresh((session.expires_in || 900) * 1000);
+}
+
+function scheduleRefresh(expiresInMs) {
+  clearTimeout(refreshTimer);
+  // Refresh a minute before expiry.
+  refreshTimer = setTimeout(refreshSession, Math.max(expiresInMs - 60000, 5000));
+}
+
+async function refreshSession() {
+  const refreshToken = localStorage.getItem("refreshToken");
+  if (!refreshToken) return;
+  try {
+    const response = await fetch("/auth/refresh", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    const data = await response.json();
+    if (response.ok) {
+      storeSession(data.data || data);
+    } else {
+      handleLogout();
+    }
+  } catch (error) {
+    console.error("[v0] Token refresh failed:", error);
+  }
+}
+
 function handleLogout() {
   console.log("[v0] Handling logout");
+  const refreshToken = localStorage.getItem("refreshToken");
+  if (refreshToken) {
+    fetch("/auth/logout", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    }).catch(() => {});
+  }
+  clearTimeout(refreshTimer);
   localStorage.removeItem("token");
+  localStorage.removeItem("refreshToken");
   currentUser = null;
   showHomepage();
   showToast("Logged out successfully", "success");
@@ -381,13 +634,68 @@ function filterFeed(section) {
   loadFeed();
 }
 
+// Trending tags come from a snapshot the server refreshes periodically.
+async function loadTrendingTags() {
+  const container = document.getElementById("trendingTags");
+  if (!container) return;
+  try {
+    const response = await fetch("/tags/trending?limit=10");
+    if (!response.ok) return;
+    const data = await response.json();
+    const tags = (data.data || data).tags || [];
+    container.innerHTML = "";
+    tags.forEach((t) => {
+      const chip = document.createElement("span");
+      chip.className = "tag";
+      chip.textContent = `🔥 ${t.tag}`;
+      chip.addEventListener("click", () => loadTagPage(t.tag));
+      container.appendChild(chip);
+    });
+  } catch (error) {
+    console.error("[v0] Loading trending tags failed:", error);
+  }
+}
+
+async function loadTagPage(tag) {
+  const feedContainer = document.getElementById("feedContainer");
+  feedContainer.innerHTML = '<div class="loading-spinner"></div>';
+  try {
+    const response = await fetch(`/tags/${encodeURIComponent(tag.replace(/^#/, ""))}`, {
+      headers: { Authorization: `Bearer ${localStorage.getItem("token")}` },
+    });
+    const data = await response.json();
+    const result = data.data || data;
+    if (!response.ok) {
+      showToast((data.error && data.error.message) || "Could not load tag", "error");
+      return;
+    }
+    const header = document.createElement("div");
+    header.className = "empty-state";
+    header.innerHTML = "<h3></h3><p></p>";
+    header.querySelector("h3").textContent = result.tag;
+    header.querySelector("p").textContent = `${result.total_posts} posts`;
+    feedContainer.innerHTML = (result.posts || []).map((post) => createFeedItem(post)).join("");
+    feedContainer.prepend(header);
+  } catch (error) {
+    console.error("[v0] Loading tag failed:", error);
+    showToast("Network error. Please try again.", "error");
+  }
+}
+
 async function loadFeed() {
   console.log("[v0] Loading feed for section:", currentSection);
+  loadTrendingTags();
   const feedContainer = document.getElementById("feedContainer");
   feedContainer.innerHTML = '<div class="loading-spinner"></div>';
   try {
-    const url =
-      currentSection === "all" ? "/feed" : `/feed?section=${currentSection}`;
+    let url = "/feed";
+    if (currentSection === "for-you") {
+      url = "/feed/for-you";
+    } else if (currentSection === "following") {
+      url = "/feed/following";
+    } else if (currentSection !== "all") {
+      url = `/feed?section=${currentSection}`;
+    }
     console.log("[v0] Fetching feed from:", url);
     const response = await fetch(url, {
       headers: {
@@ -404,6 +712,8 @@ async function loadFeed() {
       posts = data;
     } else if (data.data && Array.isArray(data.data)) {
       posts = data.data;
+    } else if (data.data && Array.isArray(data.data.posts)) {
+      posts = data.data.posts;
     } else if (data.posts && Array.isArray(data.posts)) {
       posts = data.posts;
     } else {
@@ -424,7 +734,9 @@ async function loadFeed() {
                     <p>Be the first to share something with the community!</p>
                     <p style="font-size: 0.9em; color: #666; margin-top: 10px;">
                         ${
-                          currentSection === "all"
+                          currentSection === "following"
+                            ? "Follow people from their posts to see them here."
+                            : currentSection === "all" || currentSection === "for-you"
                             ? "Try uploading some content!"
                             : `No ${currentSection} posts found.`
                         }
@@ -447,6 +759,43 @@ async function loadFeed() {
   }
 }
 
+async function searchPosts() {
+  const q = document.getElementById("searchInput").value.trim();
+  if (!q) {
+    loadFeed();
+    return;
+  }
+  const feedContainer = document.getElementById("feedContainer");
+  feedContainer.innerHTML = '<div class="loading-spinner"></div>';
+  const params = new URLSearchParams({ q });
+  if (currentSection === "remedies" || currentSection === "experience") {
+    params.set("section", currentSection);
+  }
+  try {
+    const response = await fetch(`/search?${params}`, {
+      headers: { Authorization: `Bearer ${localStorage.getItem("token")}` },
+    });
+    const data = await response.json();
+    if (!response.ok) {
+      showToast((data.error && data.error.message) || "Search failed", "error");
+      return;
+    }
+    const results = (data.data || data).results || [];
+    if (results.length === 0) {
+      feedContainer.innerHTML = '<div class="empty-state"><h3></h3></div>';
+      feedContainer.querySelector("h3").textContent = `No posts match "${q}"`;
+      return;
+    }
+    // The snippet is escaped server-side and highlights the matches.
+    feedContainer.innerHTML = results
+      .map((r) => createFeedItem({ ...r, content: r.snippet }))
+      .join("");
+  } catch (error) {
+    console.error("[v0] Search failed:", error);
+    showToast("Network error. Please try again.", "error");
+  }
+}
+
 function createFeedItem(post) {
   const timeAgo = getTimeAgo(new Date(post.created_at));
   const userName = post.user_name || "Community Member";
@@ -498,6 +847,14 @@ ${post.tags.map((tag) => `<span class="tag">${tag}</span>`).join("")}
 </div>
 `
       : "";
+  // Everything on the following feed is by someone already followed.
+  const following = currentSection === "following";
+  const followHtml =
+    post.user_id && currentUser && post.user_id !== currentUser.id
+      ? `<button class="follow-btn${following ? " following" : ""}" onclick="toggleFollow('${
+          post.user_id
+        }', this)">${following ? "Following" : "Follow"}</button>`
+      : "";
   return `
         <div class="feed-item">
             <div class="feed-item-header">
@@ -509,17 +866,18 @@ ${post.tags.map((tag) => `<span class="tag">${tag}</span>`).join("")}
                 <span class="section-badge ${post.section}">${
     post.section === "remedies" ? "🌿 Remedies" : "📖 Experience"
   }</span>
+                ${followHtml}
             </div>
             <div class="feed-item-content">
                 ${mediaHtml}
                 ${contentHtml}
                 ${tagsHtml}
                 <div class="feed-item-actions">
-                    <button class="action-btn" onclick="toggleLike('${
-                      post._id
-                    }')">
+                    <button class="action-btn like-btn${
+                      post.liked_by_me ? " liked" : ""
+                    }" onclick="toggleLike('${post._id}', this)">
                         <i class="fas fa-heart"></i>
-                        <span>Like</span>
+                        <span>${likeLabel(post.likes)}</span>
                     </button>
                     <button class="action-btn" onclick="sharePost('${
                       post._id
@@ -741,7 +1099,6 @@ async function handleUpload() {
     // Send to backend
     console.log("[v0] Sending post to backend");
     const postData = {
-      token,
       media_url: mediaUrl,
       media_type: mediaType,
       content: caption,
@@ -752,7 +1109,7 @@ async function handleUpload() {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
-        //Authorization: `Bearer ${token}`,
+        Authorization: `Bearer ${token}`,
       },
       body: JSON.stringify(postData),
     });
@@ -804,6 +1161,17 @@ async function loadUserProfile() {
     document.getElementById("remediesCount").textContent = "0";
     document.getElementById("experienceCount").textContent = "0";
     document.getElementById("helpCount").textContent = "0";
+    const response = await fetch("/profile", {
+      headers: { Authorization: `Bearer ${token}` },
+    });
+    if (response.ok) {
+      const data = await response.json();
+      const profile = data.data || data;
+      document.getElementById("followersCount").textContent =
+        profile.followers_count || 0;
+      document.getElementById("followingCount").textContent =
+        profile.following_count || 0;
+    }
   } catch (error) {
     console.error("[v0] Error loading profile:", error);
   }
@@ -830,11 +1198,9 @@ async function loadUserPosts(section = "remedies") {
     }
 
     const response = await fetch(`/user/posts?section=${section}`, {
-      method: "POST", // Change to POST to send token in body
       headers: {
-        "Content-Type": "application/json",
+        Authorization: `Bearer ${token}`,
       },
-      body: JSON.stringify({ token }),
     });
     console.log("[v0] User posts response status:", response.status);
     if (response.ok) {
@@ -949,12 +1315,59 @@ function getMediaDuration(file) {
   });
 }
 
+function likeLabel(likes) {
+  return likes > 0 ? `Like · ${likes}` : "Like";
+}
+
+async function toggleLike(postId, button) {
+  const liked = button.classList.contains("liked");
+  try {
+    const response = await fetch(`/posts/${postId}/like`, {
+      method: liked ? "DELETE" : "POST",
+      headers: { Authorization: `Bearer ${localStorage.getItem("token")}` },
+    });
+    const data = await response.json();
+    if (!response.ok) {
+      showToast((data.error && data.error.message) || "Could not update like", "error");
+      return;
+    }
+    const post = data.data || data;
+    button.classList.toggle("liked", post.liked_by_me);
+    button.querySelector("span").textContent = likeLabel(post.likes);
+  } catch (error) {
+    console.error("[v0] Like failed:", error);
+    showToast("Network error. Please try again.", "error");
+  }
+}
+
 // Placeholder functions for feed actions
-function toggleLike(postId) {
-  console.log("[v0] Toggle like for post:", postId);
-  showToast("Like feature coming soon!", "info");
+
+async function toggleFollow(userId, button) {
+  const following = button.classList.contains("following");
+  try {
+    const response = await fetch(`/users/${userId}/follow`, {
+      method: following ? "DELETE" : "POST",
+      headers: { Authorization: `Bearer ${localStorage.getItem("token")}` },
+    });
+    const data = await response.json();
+    if (!response.ok) {
+      showToast((data.error && data.error.message) || "Could not update follow", "error");
+      return;
+    }
+    // Update every post by this author on the page.
+    document
+      .querySelectorAll(`.follow-btn[onclick*="'${userId}'"]`)
+      .forEach((btn) => {
+        btn.classList.toggle("following", !following);
+        btn.textContent = following ? "Follow" : "Following";
+      });
+  } catch (error) {
+    console.error("[v0] Follow failed:", error);
+    showToast("Network error. Please try again.", "error");
+  }
 }
 
+// Placeholder functions for feed actions
 function sharePost(postId) {
   console.log("[v0] Share post:", postId);
   showToast("Share feature coming soon!", "info");
@@ -0,0 +1,142 @@
+// Package ranking scores posts for a reader's personalized feed. Scoring is
+// a pure function of the posts, the reader's profile, the weights and the
+// time passed in, so the same inputs always give the same order.
+package ranking
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"finalapp/hashtag"
+	"finalapp/models"
+)
+
+// Weights tune the for-you feed. A post scores
+//
+//	(Base + PreferredTag*p + LikedTag*l + Engagement*ln(1+likes+comments)) * decay
+//
+// where p is the number of the reader's preferred tags on the post, l is the
+// summed affinity (0..1 per tag) of the post's tags among posts the reader
+// liked, and decay halves every HalfLifeHours. Zero fields take the default.
+type Weights struct {
+	Base          float64 `json:"base"`
+	PreferredTag  float64 `json:"preferred_tag"`
+	LikedTag      float64 `json:"liked_tag"`
+	Engagement    float64 `json:"engagement"`
+	HalfLifeHours float64 `json:"half_life_hours"`
+}
+
+// DefaultWeights is used for any weight left unset.
+var DefaultWeights = Weights{
+	Base:          1,
+	PreferredTag:  3,
+	LikedTag:      2,
+	Engagement:    0.5,
+	HalfLifeHours: 48,
+}
+
+// WithDefaults returns w with unset fields filled from DefaultWeights.
+func (w Weights) WithDefaults() Weights {
+	fill := func(v *float64, d float64) {
+		if *v == 0 {
+			*v = d
+		}
+	}
+	fill(&w.Base, DefaultWeights.Base)
+	fill(&w.PreferredTag, DefaultWeights.PreferredTag)
+	fill(&w.LikedTag, DefaultWeights.LikedTag)
+	fill(&w.Engagement, DefaultWeights.Engagement)
+	fill(&w.HalfLifeHours, DefaultWeights.HalfLifeHours)
+	return w
+}
+
+// Profile is what the ranking knows about a reader's interests.
+type Profile struct {
+	Preferred map[string]bool
+	// Liked maps each tag seen on the reader's liked posts to how often it
+	// appeared, relative to the most frequent one.
+	Liked map[string]float64
+}
+
+// NewProfile builds a profile from the reader's preferred tags and the posts
+// they have liked.
+func NewProfile(preferred []string, liked []models.Post) Profile {
+	prof := Profile{Preferred: map[string]bool{}, Liked: map[string]float64{}}
+	for _, t := range preferred {
+		if t = normalizeTag(t); t != "" {
+			prof.Preferred[t] = true
+		}
+	}
+	top := 0.0
+	for _, p := range liked {
+		for _, t := range uniqueTags(p.Tags) {
+			prof.Liked[t]++
+			top = math.Max(top, prof.Liked[t])
+		}
+	}
+	for t := range prof.Liked {
+		prof.Liked[t] /= top
+	}
+	return prof
+}
+
+// Score returns the post's for-you score at time now.
+func Score(p models.Post, prof Profile, w Weights, now time.Time) float64 {
+	w = w.WithDefaults()
+	preferred, liked := 0.0, 0.0
+	for _, t := range uniqueTags(p.Tags) {
+		if prof.Preferred[t] {
+			preferred++
+		}
+		liked += prof.Liked[t]
+	}
+	engagement := math.Log1p(float64(max(p.Likes, 0) + max(p.CommentCount, 0)))
+	ageHours := math.Max(now.Sub(p.CreatedAt).Hours(), 0)
+	decay := math.Exp2(-ageHours / w.HalfLifeHours)
+	return (w.Base + w.PreferredTag*preferred + w.LikedTag*liked + w.Engagement*engagement) * decay
+}
+
+// Scored is a post with its score.
+type Scored struct {
+	Post  models.Post
+	Score float64
+}
+
+// Rank scores posts and orders them best first. Equal scores fall back to
+// newest first, then to _id, so the order is total.
+func Rank(posts []models.Post, prof Profile, w Weights, now time.Time) []Scored {
+	out := make([]Scored, len(posts))
+	for i, p := range posts {
+		out[i] = Scored{Post: p, Score: Score(p, prof, w, now)}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.Post.CreatedAt.Equal(b.Post.CreatedAt) {
+			return a.Post.CreatedAt.After(b.Post.CreatedAt)
+		}
+		return a.Post.ID.Hex() > b.Post.ID.Hex()
+	})
+	return out
+}
+
+// normalizeTag puts tags in the form posts store them in, so they match
+// regardless of case, punctuation and a leading '#'.
+func normalizeTag(t string) string {
+	return hashtag.Clean(t)
+}
+
+func uniqueTags(tags []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range tags {
+		if t = normalizeTag(t); t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"finalapp/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var now = time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+// unit weights make each term of the score easy to read off.
+var unit = Weights{Base: 1, PreferredTag: 1, LikedTag: 1, Engagement: 1, HalfLifeHours: 24}
+
+func TestScore(t *testing.T) {
+	liked := []models.Post{
+		{Tags: []string{"#ginger", "#tea"}},
+		{Tags: []string{"#Ginger"}},
+	}
+	prof := NewProfile([]string{"Turmeric", "#TEA"}, liked)
+	for _, tc := range []struct {
+		name string
+		post models.Post
+		want float64
+	}{
+		{"no signal", models.Post{Tags: []string{"#other"}, CreatedAt: now}, 1},
+		{"preferred tag", models.Post{Tags: []string{"#turmeric"}, CreatedAt: now}, 2},
+		{"preferred tag in another form", models.Post{Tags: []string{"turmeric!"}, CreatedAt: now}, 2},
+		{"repeated tag counts once", models.Post{Tags: []string{"#turmeric", "#Turmeric"}, CreatedAt: now}, 2},
+		// #ginger is on both liked posts, #tea on one of two.
+		{"liked tag", models.Post{Tags: []string{"#ginger"}, CreatedAt: now}, 2},
+		{"liked and preferred", models.Post{Tags: []string{"#tea"}, CreatedAt: now}, 1 + 1 + 0.5},
+		{"engagement", models.Post{Likes: 2, CommentCount: 1, CreatedAt: now}, 1 + math.Log1p(3)},
+		{"negative counts ignored", models.Post{Likes: -5, CreatedAt: now}, 1},
+		{"one half-life", models.Post{CreatedAt: now.Add(-24 * time.Hour)}, 0.5},
+		{"two half-lives", models.Post{Tags: []string{"#turmeric"}, CreatedAt: now.Add(-48 * time.Hour)}, 0.5},
+		{"future post not boosted", models.Post{CreatedAt: now.Add(time.Hour)}, 1},
+	} {
+		if got := Score(tc.post, prof, unit, now); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: Score = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWeightsWithDefaults(t *testing.T) {
+	if got := (Weights{}).WithDefaults(); got != DefaultWeights {
+		t.Fatalf("zero weights = %+v, want defaults", got)
+	}
+	if got := (Weights{Engagement: 7}).WithDefaults(); got.Engagement != 7 || got.Base != DefaultWeights.Base {
+		t.Fatalf("partial weights = %+v", got)
+	}
+}
+
+func TestRankOrder(t *testing.T) {
+	lo, hi := primitive.NewObjectIDFromTimestamp(now), primitive.NewObjectIDFromTimestamp(now)
+	if lo.Hex() > hi.Hex() {
+		lo, hi = hi, lo
+	}
+	older := primitive.NewObjectIDFromTimestamp(now.Add(-time.Hour))
+	prof := NewProfile([]string{"#ginger"}, nil)
+	// With a very long half-life the two-hour-old preferred post outscores
+	// the rest; the others tie on score and fall back to time, then _id.
+	w := unit
+	w.HalfLifeHours = 1e12
+	posts := []models.Post{
+		{ID: older, CreatedAt: now.Add(-time.Hour)},
+		{ID: lo, CreatedAt: now},
+		{ID: primitive.NewObjectID(), Tags: []string{"#ginger"}, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: hi, CreatedAt: now},
+	}
+	got := Rank(posts, prof, w, now)
+	want := []primitive.ObjectID{posts[2].ID, hi, lo, older}
+	for i, s := range got {
+		if s.Post.ID != want[i] {
+			t.Fatalf("position %d = %v, want %v", i, s.Post.ID, want[i])
+		}
+	}
+	// The order does not depend on the input order.
+	posts[0], posts[3] = posts[3], posts[0]
+	for i, s := range Rank(posts, prof, w, now) {
+		if s.Post.ID != want[i] {
+			t.Fatalf("reordered input: position %d = %v, want %v", i, s.Post.ID, want[i])
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"finalapp/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	SortNewest    = "newest"
+	SortMostLiked = "most_liked"
+
+	DefaultFeedLimit = 20
+	MaxFeedLimit     = 100
+)
+
+// ErrInvalidCursor is returned when a feed cursor cannot be decoded or was
+// issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// FeedQuery selects one page of the feed. Section, Tag and UserID are
+// optional filters; empty values match every post. Tag is matched exactly, so
+// callers pass it in the form posts store it in. Authors, when UserID is
+// empty and Authors is not nil, keeps only posts by those users, so an empty
+// list matches nothing.
+type FeedQuery struct {
+	Limit   int
+	Cursor  string
+	Sort    string
+	Section string
+	Tag     string
+	UserID  string
+	Authors []string
+}
+
+// FeedPage is one page of posts plus the cursor for the next page, which is
+// empty once the feed is exhausted.
+type FeedPage struct {
+	Posts      []models.Post `json:"posts"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// ValidSort reports whether s is a supported feed sort order.
+func ValidSort(s string) bool {
+	return s == SortNewest || s == SortMostLiked
+}
+
+// normalize fills in defaults and clamps the limit.
+func (q FeedQuery) normalize() FeedQuery {
+	if q.Sort == "" {
+		q.Sort = SortNewest
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultFeedLimit
+	}
+	if q.Limit > MaxFeedLimit {
+		q.Limit = MaxFeedLimit
+	}
+	return q
+}
+
+// matches reports whether p passes the query's filters.
+func (q FeedQuery) matches(p models.Post) bool {
+	if q.Section != "" && p.Section != q.Section {
+		return false
+	}
+	if q.UserID != "" && p.UserID != q.UserID {
+		return false
+	}
+	if q.UserID == "" && q.Authors != nil && !slices.Contains(q.Authors, p.UserID) {
+		return false
+	}
+	if q.Tag != "" {
+		for _, t := range p.Tags {
+			if t == q.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// feedCursor is the position of the last post on a page. It is serialized as
+// base64 JSON so clients treat it as opaque.
+type feedCursor struct {
+	Sort      string             `json:"s"`
+	Likes     int                `json:"l,omitempty"`
+	CreatedAt time.Time          `json:"c"`
+	ID        primitive.ObjectID `json:"i"`
+}
+
+func encodeCursor(sort string, p models.Post) string {
+	b, _ := json.Marshal(feedCursor{Sort: sort, Likes: p.Likes, CreatedAt: p.CreatedAt, ID: p.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(sort, s string) (*feedCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c feedCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// after reports whether p sorts strictly after the cursor position.
+func (c *feedCursor) after(p models.Post) bool {
+	if c.Sort == SortMostLiked && p.Likes != c.Likes {
+		return p.Likes < c.Likes
+	}
+	if !p.CreatedAt.Equal(c.CreatedAt) {
+		return p.CreatedAt.Before(c.CreatedAt)
+	}
+	return p.ID.Hex() < c.ID.Hex()
+}
+
+// less orders posts for the given sort, newest first with _id as tiebreaker.
+func less(sort string, a, b models.Post) bool {
+	if sort == SortMostLiked && a.Likes != b.Likes {
+		return a.Likes > b.Likes
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID.Hex() > b.ID.Hex()
+}
+
+// page trims a result set fetched with limit+1 and computes the next cursor.
+func page(sort string, posts []models.Post, limit int) FeedPage {
+	if len(posts) <= limit {
+		return FeedPage{Posts: posts}
+	}
+	posts = posts[:limit]
+	return FeedPage{Posts: posts, NextCursor: encodeCursor(sort, posts[limit-1])}
+}
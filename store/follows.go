@@ -0,0 +1,60 @@
+package store
+
+import (
+	"encoding/base64"
+
+	"finalapp/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultFollowLimit = 50
+	MaxFollowLimit     = 200
+)
+
+// FollowQuery selects one page of a follower or following list, most recent
+// follow first. Cursor is the NextCursor of the previous page.
+type FollowQuery struct {
+	Limit  int
+	Cursor string
+}
+
+// FollowPage is one page of follows.
+type FollowPage struct {
+	Follows    []models.Follow
+	NextCursor string
+}
+
+// normalize clamps the limit and decodes the cursor, the _id of the last
+// follow on the previous page.
+func (q FollowQuery) normalize() (FollowQuery, *primitive.ObjectID, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultFollowLimit
+	}
+	if q.Limit > MaxFollowLimit {
+		q.Limit = MaxFollowLimit
+	}
+	if q.Cursor == "" {
+		return q, nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return q, nil, ErrInvalidCursor
+	}
+	oid, err := primitive.ObjectIDFromHex(string(b))
+	if err != nil {
+		return q, nil, ErrInvalidCursor
+	}
+	return q, &oid, nil
+}
+
+// followPage trims follows fetched with limit+1 and computes the next cursor.
+func followPage(follows []models.Follow, limit int) FollowPage {
+	if len(follows) <= limit {
+		return FollowPage{Follows: follows}
+	}
+	follows = follows[:limit]
+	last := follows[limit-1].ID.Hex()
+	return FollowPage{Follows: follows, NextCursor: base64.RawURLEncoding.EncodeToString([]byte(last))}
+}
@@ -0,0 +1,1107 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"finalapp/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewMemoryRepositories returns repositories that keep everything in process
+// memory. They are intended for tests and local development without MongoDB.
+func NewMemoryRepositories() Repositories {
+	return Repositories{
+		Users:    &memoryUserRepo{byID: map[primitive.ObjectID]models.User{}},
+		Posts:    &memoryPostRepo{},
+		Comments: &memoryCommentRepo{},
+		Tokens:   &memoryTokenRepo{},
+
+		AccountTokens: &memoryAccountTokenRepo{},
+		LoginAttempts: &memoryLoginAttemptRepo{byKey: map[string]models.LoginAttempt{}},
+		Delegations:   &memoryDelegationRepo{},
+		Audit:         &memoryAuditRepo{},
+		Devices:       &memoryDeviceRepo{byID: map[primitive.ObjectID]models.Device{}},
+		OIDCSessions:  &memoryOIDCSessionRepo{byState: map[string]models.OIDCSession{}},
+		Trends:        &memoryTrendRepo{byWindow: map[string]models.TrendingSnapshot{}},
+		Taxonomy:      &memoryTaxonomyRepo{byTag: map[string]models.TagDefinition{}},
+		Follows:       &memoryFollowRepo{},
+	}
+}
+
+type memoryUserRepo struct {
+	mu   sync.RWMutex
+	byID map[primitive.ObjectID]models.User
+}
+
+func (r *memoryUserRepo) Create(_ context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.byID {
+		if u.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	user.ID = primitive.NewObjectID()
+	r.byID[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepo) FindByID(_ context.Context, id string) (*models.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.byID[oid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (r *memoryUserRepo) FindByIDs(_ context.Context, ids []string) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var users []models.User
+	for _, id := range ids {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			continue
+		}
+		if u, ok := r.byID[oid]; ok {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepo) FindByEmail(_ context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, u := range r.byID {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepo) UpdateProfile(_ context.Context, id string, p models.ProfileUpdateRequest, at time.Time) (*models.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byID[oid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	u.Name, u.Bio, u.Location, u.ProfilePicture = p.Name, p.Bio, p.Location, p.ProfilePicture
+	u.UpdatedAt = at
+	r.byID[oid] = u
+	return &u, nil
+}
+
+func (r *memoryUserRepo) SetPasswordHash(_ context.Context, id, hash string) error {
+	return r.update(id, func(u *models.User) { u.Password = hash })
+}
+
+func (r *memoryUserRepo) LinkNeighbour(_ context.Context, id, neighbourID, role string) error {
+	return r.update(id, func(u *models.User) { u.NeighbourID, u.Role = neighbourID, role })
+}
+
+func (r *memoryUserRepo) SetRole(_ context.Context, id, role string) error {
+	return r.update(id, func(u *models.User) { u.Role = role })
+}
+
+func (r *memoryUserRepo) SetEmailVerified(_ context.Context, id string) error {
+	return r.update(id, func(u *models.User) { u.EmailVerified = true })
+}
+
+func (r *memoryUserRepo) FindByExternalLogin(_ context.Context, issuer, subject string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, u := range r.byID {
+		for _, l := range u.ExternalLogins {
+			if l.Issuer == issuer && l.Subject == subject {
+				return &u, nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepo) AddExternalLogin(_ context.Context, id string, login models.ExternalLogin) error {
+	return r.update(id, func(u *models.User) {
+		for _, l := range u.ExternalLogins {
+			if l == login {
+				return
+			}
+		}
+		u.ExternalLogins = append(append([]models.ExternalLogin{}, u.ExternalLogins...), login)
+	})
+}
+
+func (r *memoryUserRepo) SetTwoFactor(_ context.Context, id string, tf *models.TwoFactor) error {
+	if tf != nil {
+		c := *tf
+		c.RecoveryCodes = append([]string(nil), tf.RecoveryCodes...)
+		tf = &c
+	}
+	return r.update(id, func(u *models.User) { u.TwoFactor = tf })
+}
+
+func (r *memoryUserRepo) UseTOTPStep(_ context.Context, id string, step int64) (bool, error) {
+	used := false
+	err := r.update(id, func(u *models.User) {
+		if u.TwoFactor == nil || !u.TwoFactor.Enabled || u.TwoFactor.LastStep >= step {
+			return
+		}
+		c := *u.TwoFactor
+		c.LastStep = step
+		u.TwoFactor = &c
+		used = true
+	})
+	return used, err
+}
+
+func (r *memoryUserRepo) UseRecoveryCode(_ context.Context, id, hash string) (bool, error) {
+	used := false
+	err := r.update(id, func(u *models.User) {
+		if u.TwoFactor == nil || !u.TwoFactor.Enabled {
+			return
+		}
+		for _, h := range u.TwoFactor.RecoveryCodes {
+			if h == hash {
+				c := *u.TwoFactor
+				c.RecoveryCodes = setMember(c.RecoveryCodes, hash, false)
+				u.TwoFactor = &c
+				used = true
+				return
+			}
+		}
+	})
+	return used, err
+}
+
+// update applies fn to the stored user and stamps UpdatedAt.
+func (r *memoryUserRepo) update(id string, fn func(*models.User)) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byID[oid]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&u)
+	u.UpdatedAt = time.Now()
+	r.byID[oid] = u
+	return nil
+}
+
+func (r *memoryUserRepo) SetLikedPost(_ context.Context, userID, postID string, liked bool) error {
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byID[oid]
+	if !ok {
+		return ErrNotFound
+	}
+	u.LikedPosts = setMember(u.LikedPosts, postID, liked)
+	r.byID[oid] = u
+	return nil
+}
+
+// setMember returns list with v present or absent. It never modifies list in
+// place, so copies handed out earlier are unaffected.
+func setMember(list []string, v string, present bool) []string {
+	out := make([]string, 0, len(list)+1)
+	for _, x := range list {
+		if x != v {
+			out = append(out, x)
+		}
+	}
+	if present {
+		out = append(out, v)
+	}
+	return out
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryPostRepo struct {
+	mu    sync.RWMutex
+	posts []models.Post
+}
+
+func (r *memoryPostRepo) Create(_ context.Context, post *models.Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	post.ID = primitive.NewObjectID()
+	r.posts = append(r.posts, *post)
+	return nil
+}
+
+func (r *memoryPostRepo) FindByID(_ context.Context, id string) (*models.Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i := r.index(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	p := r.posts[i]
+	return &p, nil
+}
+
+func (r *memoryPostRepo) Like(_ context.Context, postID, userID string) (*models.Post, bool, error) {
+	return r.setLike(postID, userID, true)
+}
+
+func (r *memoryPostRepo) Unlike(_ context.Context, postID, userID string) (*models.Post, bool, error) {
+	return r.setLike(postID, userID, false)
+}
+
+func (r *memoryPostRepo) setLike(postID, userID string, liked bool) (*models.Post, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(postID)
+	if i < 0 {
+		return nil, false, ErrNotFound
+	}
+	p := &r.posts[i]
+	changed := contains(p.LikedBy, userID) != liked
+	if changed {
+		p.LikedBy = setMember(p.LikedBy, userID, liked)
+		if liked {
+			p.Likes++
+		} else {
+			p.Likes = max(p.Likes-1, 0)
+		}
+	}
+	out := *p
+	return &out, changed, nil
+}
+
+func (r *memoryPostRepo) AddComments(_ context.Context, postID string, delta int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(postID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.posts[i].CommentCount += delta
+	return nil
+}
+
+func (r *memoryPostRepo) UpdateContent(_ context.Context, id, content string, tags []string, at time.Time) (*models.Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	r.posts[i].Content = content
+	r.posts[i].Tags = tags
+	r.posts[i].UpdatedAt = at
+	p := r.posts[i]
+	return &p, nil
+}
+
+func (r *memoryPostRepo) SoftDelete(_ context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.posts[i].DeletedAt = &at
+	r.posts[i].UpdatedAt = at
+	return nil
+}
+
+// index returns the position of the live post with the given hex ID, or -1.
+// Callers must hold r.mu.
+func (r *memoryPostRepo) index(id string) int {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return -1
+	}
+	for i, p := range r.posts {
+		if p.ID == oid && p.DeletedAt == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *memoryPostRepo) Feed(_ context.Context, q FeedQuery) (FeedPage, error) {
+	q = q.normalize()
+	cur, err := decodeCursor(q.Sort, q.Cursor)
+	if err != nil {
+		return FeedPage{}, err
+	}
+	posts := r.filter(func(p models.Post) bool { return q.matches(p) && (cur == nil || cur.after(p)) })
+	sort.Slice(posts, func(i, j int) bool { return less(q.Sort, posts[i], posts[j]) })
+	if len(posts) > q.Limit+1 {
+		posts = posts[:q.Limit+1]
+	}
+	return page(q.Sort, posts, q.Limit), nil
+}
+
+func (r *memoryPostRepo) Recent(_ context.Context, section string, since, until time.Time, limit int) ([]models.Post, error) {
+	posts := r.filter(func(p models.Post) bool {
+		return p.CreatedAt.After(since) && !p.CreatedAt.After(until) && (section == "" || p.Section == section)
+	})
+	sort.Slice(posts, func(i, j int) bool { return less(SortNewest, posts[i], posts[j]) })
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+func (r *memoryPostRepo) FindByIDs(_ context.Context, ids []string) ([]models.Post, error) {
+	want := map[string]bool{}
+	for _, id := range ids {
+		want[id] = true
+	}
+	return r.filter(func(p models.Post) bool { return want[p.ID.Hex()] }), nil
+}
+
+// Search approximates MongoDB's $text search: any term may match, and
+// phrases and negation are not supported.
+func (r *memoryPostRepo) Search(_ context.Context, q SearchQuery) (SearchPage, error) {
+	q, offset, err := q.normalize()
+	if err != nil {
+		return SearchPage{}, err
+	}
+	terms := SearchTerms(q.Text)
+	var hits []SearchHit
+	for _, p := range r.filter(func(p models.Post) bool { return q.Section == "" || p.Section == q.Section }) {
+		if s := searchScore(p, terms); s > 0 {
+			hits = append(hits, SearchHit{Post: p, Score: s})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return less(SortNewest, hits[i].Post, hits[j].Post)
+	})
+	if offset >= len(hits) {
+		return SearchPage{}, nil
+	}
+	return searchPage(hits[offset:], offset, q.Limit), nil
+}
+
+func (r *memoryPostRepo) TagActivity(_ context.Context, since, until time.Time) ([]models.TagActivity, error) {
+	byTag := map[string]*models.TagActivity{}
+	authors := map[string]map[string]bool{}
+	var order []string
+	for _, p := range r.filter(func(p models.Post) bool { return !p.CreatedAt.Before(since) && p.CreatedAt.Before(until) }) {
+		for _, t := range p.Tags {
+			a, ok := byTag[t]
+			if !ok {
+				a = &models.TagActivity{Tag: t}
+				byTag[t] = a
+				authors[t] = map[string]bool{}
+				order = append(order, t)
+			}
+			a.Posts++
+			a.Likes += p.Likes
+			a.Comments += p.CommentCount
+			authors[t][p.UserID] = true
+		}
+	}
+	out := make([]models.TagActivity, 0, len(order))
+	for _, t := range order {
+		byTag[t].Authors = len(authors[t])
+		out = append(out, *byTag[t])
+	}
+	return out, nil
+}
+
+func (r *memoryPostRepo) SetTags(_ context.Context, id string, tags []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.posts[i].Tags = tags
+	return nil
+}
+
+func (r *memoryPostRepo) CountByTag(_ context.Context, tag string) (int, error) {
+	return len(r.filter(func(p models.Post) bool { return FeedQuery{Tag: tag}.matches(p) })), nil
+}
+
+func (r *memoryPostRepo) ListByUser(_ context.Context, userID, section string) ([]models.Post, error) {
+	return r.filter(func(p models.Post) bool {
+		return p.UserID == userID && (section == "" || p.Section == section)
+	}), nil
+}
+
+func (r *memoryPostRepo) CountBySection(_ context.Context, userID string) (map[string]int, error) {
+	counts := map[string]int{}
+	for _, p := range r.filter(func(p models.Post) bool { return p.UserID == userID }) {
+		counts[p.Section]++
+	}
+	return counts, nil
+}
+
+func (r *memoryPostRepo) filter(keep func(models.Post) bool) []models.Post {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []models.Post
+	for _, p := range r.posts {
+		if p.DeletedAt == nil && keep(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+type memoryCommentRepo struct {
+	mu       sync.RWMutex
+	comments []models.Comment
+}
+
+func (r *memoryCommentRepo) Create(_ context.Context, comment *models.Comment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	comment.ID = primitive.NewObjectID()
+	r.comments = append(r.comments, *comment)
+	return nil
+}
+
+func (r *memoryCommentRepo) FindByID(_ context.Context, id string) (*models.Comment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.comments {
+		if c.ID.Hex() == id {
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryCommentRepo) ListByPost(_ context.Context, postID string) ([]models.Comment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []models.Comment
+	for _, c := range r.comments {
+		if c.PostID == postID {
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *memoryCommentRepo) UpdateContent(_ context.Context, id, content string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.comments {
+		if r.comments[i].ID.Hex() == id {
+			r.comments[i].Content = content
+			r.comments[i].UpdatedAt = at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryCommentRepo) Delete(_ context.Context, id string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.comments[:0:0]
+	for _, c := range r.comments {
+		if c.ID.Hex() != id && c.ParentID != id {
+			kept = append(kept, c)
+		}
+	}
+	n := len(r.comments) - len(kept)
+	if n == 0 {
+		return 0, ErrNotFound
+	}
+	r.comments = kept
+	return n, nil
+}
+
+type memoryTokenRepo struct {
+	mu     sync.Mutex
+	tokens []models.RefreshToken
+}
+
+func (r *memoryTokenRepo) CreateRefresh(_ context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.Hash == token.Hash {
+			return ErrDuplicate
+		}
+	}
+	token.ID = primitive.NewObjectID()
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+func (r *memoryTokenRepo) FindRefresh(_ context.Context, hash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.Hash == hash {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryTokenRepo) MarkUsed(_ context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		if r.tokens[i].ID == id {
+			if r.tokens[i].UsedAt != nil {
+				return false, nil
+			}
+			r.tokens[i].UsedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryTokenRepo) RevokeFamily(_ context.Context, familyID string, at time.Time) error {
+	r.revoke(func(t models.RefreshToken) bool { return t.FamilyID == familyID }, at)
+	return nil
+}
+
+func (r *memoryTokenRepo) RevokeUser(_ context.Context, userID string, at time.Time) error {
+	r.revoke(func(t models.RefreshToken) bool { return t.UserID == userID }, at)
+	return nil
+}
+
+func (r *memoryTokenRepo) revoke(match func(models.RefreshToken) bool, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		if match(r.tokens[i]) && r.tokens[i].RevokedAt == nil {
+			r.tokens[i].RevokedAt = &at
+		}
+	}
+}
+
+func (r *memoryTokenRepo) FamilyRevoked(_ context.Context, familyID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.FamilyID == familyID && t.RevokedAt != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryAccountTokenRepo struct {
+	mu     sync.Mutex
+	tokens []models.AccountToken
+}
+
+func (r *memoryAccountTokenRepo) Create(_ context.Context, token *models.AccountToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.Hash == token.Hash {
+			return ErrDuplicate
+		}
+	}
+	token.ID = primitive.NewObjectID()
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+func (r *memoryAccountTokenRepo) Consume(_ context.Context, hash, purpose string, at time.Time) (*models.AccountToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		t := &r.tokens[i]
+		if t.Hash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(at) {
+			t.UsedAt = &at
+			out := *t
+			return &out, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAccountTokenRepo) InvalidateUser(_ context.Context, userID, purpose string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		t := &r.tokens[i]
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &at
+		}
+	}
+	return nil
+}
+
+type memoryLoginAttemptRepo struct {
+	mu    sync.Mutex
+	byKey map[string]models.LoginAttempt
+}
+
+func (r *memoryLoginAttemptRepo) Find(_ context.Context, key string) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.live(key, time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &a, nil
+}
+
+func (r *memoryLoginAttemptRepo) RecordFailure(_ context.Context, key string, at time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, _ := r.live(key, at)
+	a.Key = key
+	a.Failures++
+	a.LastFailure = at
+	a.ExpiresAt = at.Add(window)
+	r.byKey[key] = a
+	return &a, nil
+}
+
+func (r *memoryLoginAttemptRepo) Lock(_ context.Context, key string, until time.Time, window time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, _ := r.live(key, time.Now())
+	a.Key = key
+	a.Failures = 0
+	a.LockedUntil = &until
+	a.ExpiresAt = until.Add(window)
+	r.byKey[key] = a
+	return nil
+}
+
+func (r *memoryLoginAttemptRepo) Clear(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byKey, key)
+	return nil
+}
+
+// live returns key's record unless it has expired, mirroring the TTL index.
+func (r *memoryLoginAttemptRepo) live(key string, now time.Time) (models.LoginAttempt, bool) {
+	a, ok := r.byKey[key]
+	if !ok || !a.ExpiresAt.After(now) {
+		return models.LoginAttempt{}, false
+	}
+	return a, true
+}
+
+type memoryDelegationRepo struct {
+	mu          sync.Mutex
+	delegations []models.Delegation
+}
+
+func (r *memoryDelegationRepo) Grant(_ context.Context, d *models.Delegation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.active(d.ElderID, d.CaregiverID); i >= 0 {
+		r.delegations[i].Scopes = d.Scopes
+		r.delegations[i].UpdatedAt = d.UpdatedAt
+		*d = r.delegations[i]
+		return nil
+	}
+	d.ID = primitive.NewObjectID()
+	d.CreatedAt = d.UpdatedAt
+	r.delegations = append(r.delegations, *d)
+	return nil
+}
+
+func (r *memoryDelegationRepo) Active(_ context.Context, elderID, caregiverID string) (*models.Delegation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.active(elderID, caregiverID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	d := r.delegations[i]
+	return &d, nil
+}
+
+func (r *memoryDelegationRepo) Revoke(_ context.Context, elderID, caregiverID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.active(elderID, caregiverID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.delegations[i].RevokedAt = &at
+	r.delegations[i].UpdatedAt = at
+	return nil
+}
+
+func (r *memoryDelegationRepo) ListByElder(_ context.Context, elderID string) ([]models.Delegation, error) {
+	return r.list(func(d models.Delegation) bool { return d.ElderID == elderID }), nil
+}
+
+func (r *memoryDelegationRepo) ListByCaregiver(_ context.Context, caregiverID string) ([]models.Delegation, error) {
+	return r.list(func(d models.Delegation) bool { return d.CaregiverID == caregiverID }), nil
+}
+
+func (r *memoryDelegationRepo) list(match func(models.Delegation) bool) []models.Delegation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.Delegation{}
+	for _, d := range r.delegations {
+		if d.RevokedAt == nil && match(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// active returns the index of the unrevoked delegation, or -1.
+func (r *memoryDelegationRepo) active(elderID, caregiverID string) int {
+	for i, d := range r.delegations {
+		if d.ElderID == elderID && d.CaregiverID == caregiverID && d.RevokedAt == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+type memoryAuditRepo struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+}
+
+func (r *memoryAuditRepo) Record(_ context.Context, entry *models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.ID = primitive.NewObjectID()
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func (r *memoryAuditRepo) ListByElder(_ context.Context, elderID string, limit int) ([]models.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.AuditEntry{}
+	for i := len(r.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		if r.entries[i].ElderID == elderID {
+			out = append(out, r.entries[i])
+		}
+	}
+	return out, nil
+}
+
+type memoryDeviceRepo struct {
+	mu   sync.Mutex
+	byID map[primitive.ObjectID]models.Device
+}
+
+func (r *memoryDeviceRepo) Create(_ context.Context, device *models.Device) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.byID {
+		if d.KeyHash == device.KeyHash {
+			return ErrDuplicate
+		}
+	}
+	device.ID = primitive.NewObjectID()
+	r.byID[device.ID] = *device
+	return nil
+}
+
+func (r *memoryDeviceRepo) FindByID(_ context.Context, id string) (*models.Device, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.byID[oid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &d, nil
+}
+
+func (r *memoryDeviceRepo) FindByKeyHash(_ context.Context, hash string) (*models.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.byID {
+		if d.KeyHash == hash {
+			return &d, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryDeviceRepo) List(_ context.Context) ([]models.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]models.Device, 0, len(r.byID))
+	for _, d := range r.byID {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *memoryDeviceRepo) Update(_ context.Context, id, name string, elderIDs, scopes []string, at time.Time) (*models.Device, error) {
+	var out models.Device
+	err := r.update(id, func(d *models.Device) {
+		d.Name, d.ElderIDs, d.Scopes, d.UpdatedAt = name, elderIDs, scopes, at
+		out = *d
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *memoryDeviceRepo) SetKey(_ context.Context, id, hash, prefix string, at time.Time) error {
+	return r.update(id, func(d *models.Device) { d.KeyHash, d.KeyPrefix, d.UpdatedAt = hash, prefix, at })
+}
+
+func (r *memoryDeviceRepo) Revoke(_ context.Context, id string, at time.Time) error {
+	return r.update(id, func(d *models.Device) { d.RevokedAt, d.UpdatedAt = &at, at })
+}
+
+func (r *memoryDeviceRepo) Touch(_ context.Context, id string, at time.Time, ip string) error {
+	return r.update(id, func(d *models.Device) { d.LastSeenAt, d.LastSeenIP = &at, ip })
+}
+
+func (r *memoryDeviceRepo) update(id string, fn func(*models.Device)) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.byID[oid]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&d)
+	r.byID[oid] = d
+	return nil
+}
+
+type memoryOIDCSessionRepo struct {
+	mu      sync.Mutex
+	byState map[string]models.OIDCSession
+}
+
+func (r *memoryOIDCSessionRepo) Create(_ context.Context, session *models.OIDCSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byState[session.StateHash]; ok {
+		return ErrDuplicate
+	}
+	session.ID = primitive.NewObjectID()
+	r.byState[session.StateHash] = *session
+	return nil
+}
+
+func (r *memoryOIDCSessionRepo) Consume(_ context.Context, stateHash string, at time.Time) (*models.OIDCSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.byState[stateHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(r.byState, stateHash)
+	if !s.ExpiresAt.After(at) {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+type memoryTrendRepo struct {
+	mu       sync.RWMutex
+	byWindow map[string]models.TrendingSnapshot
+}
+
+func (r *memoryTrendRepo) Save(_ context.Context, snap *models.TrendingSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := *snap
+	c.Tags = append([]models.TrendingTag(nil), snap.Tags...)
+	r.byWindow[snap.Window] = c
+	return nil
+}
+
+func (r *memoryTrendRepo) Latest(_ context.Context, window string) (*models.TrendingSnapshot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snap, ok := r.byWindow[window]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &snap, nil
+}
+
+type memoryTaxonomyRepo struct {
+	mu    sync.RWMutex
+	byTag map[string]models.TagDefinition
+}
+
+func (r *memoryTaxonomyRepo) List(_ context.Context) ([]models.TagDefinition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	defs := make([]models.TagDefinition, 0, len(r.byTag))
+	for _, d := range r.byTag {
+		defs = append(defs, d)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Tag < defs[j].Tag })
+	return defs, nil
+}
+
+func (r *memoryTaxonomyRepo) Put(_ context.Context, def *models.TagDefinition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := *def
+	c.Aliases = append([]string(nil), def.Aliases...)
+	r.byTag[def.Tag] = c
+	return nil
+}
+
+func (r *memoryTaxonomyRepo) Delete(_ context.Context, tag string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byTag[tag]; !ok {
+		return ErrNotFound
+	}
+	delete(r.byTag, tag)
+	return nil
+}
+
+// memoryFollowRepo keeps follows in insertion order, which is also _id
+// order.
+type memoryFollowRepo struct {
+	mu      sync.RWMutex
+	follows []models.Follow
+}
+
+func (r *memoryFollowRepo) index(followerID, followeeID string) int {
+	for i, f := range r.follows {
+		if f.FollowerID == followerID && f.FolloweeID == followeeID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *memoryFollowRepo) Follow(_ context.Context, f *models.Follow, limit int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.index(f.FollowerID, f.FolloweeID) >= 0 {
+		return false, nil
+	}
+	following := 0
+	for _, g := range r.follows {
+		if g.FollowerID == f.FollowerID {
+			following++
+		}
+	}
+	if following >= limit {
+		return false, ErrLimitReached
+	}
+	f.ID = primitive.NewObjectID()
+	r.follows = append(r.follows, *f)
+	return true, nil
+}
+
+func (r *memoryFollowRepo) Unfollow(_ context.Context, followerID, followeeID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(followerID, followeeID)
+	if i < 0 {
+		return false, nil
+	}
+	r.follows = append(r.follows[:i], r.follows[i+1:]...)
+	return true, nil
+}
+
+func (r *memoryFollowRepo) IsFollowing(_ context.Context, followerID, followeeID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.index(followerID, followeeID) >= 0, nil
+}
+
+func (r *memoryFollowRepo) Followers(_ context.Context, userID string, q FollowQuery) (FollowPage, error) {
+	return r.page(func(f models.Follow) bool { return f.FolloweeID == userID }, q)
+}
+
+func (r *memoryFollowRepo) Following(_ context.Context, userID string, q FollowQuery) (FollowPage, error) {
+	return r.page(func(f models.Follow) bool { return f.FollowerID == userID }, q)
+}
+
+func (r *memoryFollowRepo) page(match func(models.Follow) bool, q FollowQuery) (FollowPage, error) {
+	q, after, err := q.normalize()
+	if err != nil {
+		return FollowPage{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	follows := []models.Follow{}
+	for i := len(r.follows) - 1; i >= 0 && len(follows) <= q.Limit; i-- {
+		f := r.follows[i]
+		if match(f) && (after == nil || f.ID.Hex() < after.Hex()) {
+			follows = append(follows, f)
+		}
+	}
+	return followPage(follows, q.Limit), nil
+}
+
+func (r *memoryFollowRepo) FollowingIDs(_ context.Context, userID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ids []string
+	for _, f := range r.follows {
+		if f.FollowerID == userID {
+			ids = append(ids, f.FolloweeID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryFollowRepo) Counts(_ context.Context, userID string) (followers, following int, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.follows {
+		if f.FolloweeID == userID {
+			followers++
+		}
+		if f.FollowerID == userID {
+			following++
+		}
+	}
+	return followers, following, nil
+}
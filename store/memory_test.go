@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"finalapp/models"
+)
+
+func TestMemoryUserCreate(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryRepositories().Users
+	ann := models.User{Email: "ann@example.com", Name: "Ann"}
+	if err := users.Create(ctx, &ann); err != nil {
+		t.Fatal(err)
+	}
+	if ann.ID.IsZero() {
+		t.Fatal("Create did not assign an ID")
+	}
+	got, err := users.FindByEmail(ctx, "ann@example.com")
+	if err != nil || got.ID != ann.ID || got.Name != "Ann" {
+		t.Fatalf("FindByEmail = %+v, %v", got, err)
+	}
+	if got, err := users.FindByID(ctx, ann.ID.Hex()); err != nil || got.Email != ann.Email {
+		t.Fatalf("FindByID = %+v, %v", got, err)
+	}
+
+	dup := models.User{Email: "ann@example.com", Name: "Other"}
+	if err := users.Create(ctx, &dup); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("second Create err = %v, want ErrDuplicate", err)
+	}
+	if got, _ := users.FindByEmail(ctx, "ann@example.com"); got.Name != "Ann" {
+		t.Fatalf("duplicate overwrote the account: %+v", got)
+	}
+}
+
+func TestMemoryUserNotFound(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryRepositories().Users
+	if _, err := users.FindByEmail(ctx, "nobody@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindByEmail err = %v, want ErrNotFound", err)
+	}
+	for _, id := range []string{"", "not-an-id", "64b7f0c2a1b2c3d4e5f60718"} {
+		if _, err := users.FindByID(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("FindByID(%q) err = %v, want ErrNotFound", id, err)
+		}
+	}
+}
+
+// A TOTP code may be used once: its step, or any earlier one, is refused
+// after it has been accepted.
+func TestMemoryUseTOTPStep(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryRepositories().Users
+	u := models.User{Email: "ann@example.com"}
+	if err := users.Create(ctx, &u); err != nil {
+		t.Fatal(err)
+	}
+	id := u.ID.Hex()
+	if ok, err := users.UseTOTPStep(ctx, id, 100); err != nil || ok {
+		t.Fatalf("without two-factor: UseTOTPStep = %v, %v", ok, err)
+	}
+	if err := users.SetTwoFactor(ctx, id, &models.TwoFactor{Secret: "S", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		step int64
+		ok   bool
+	}{
+		{100, true},
+		{100, false},
+		{99, false},
+		{101, true},
+	} {
+		if ok, err := users.UseTOTPStep(ctx, id, tc.step); err != nil || ok != tc.ok {
+			t.Fatalf("UseTOTPStep(%d) = %v, %v; want %v", tc.step, ok, err, tc.ok)
+		}
+	}
+}
+
+func TestMemoryFollowLimit(t *testing.T) {
+	ctx := context.Background()
+	follows := NewMemoryRepositories().Follows
+	for _, id := range []string{"a", "b"} {
+		if ok, err := follows.Follow(ctx, &models.Follow{FollowerID: "u", FolloweeID: id}, 2); err != nil || !ok {
+			t.Fatalf("Follow(%s) = %v, %v", id, ok, err)
+		}
+	}
+	if _, err := follows.Follow(ctx, &models.Follow{FollowerID: "u", FolloweeID: "c"}, 2); !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("third follow err = %v, want ErrLimitReached", err)
+	}
+	// Following someone already followed is not refused at the limit.
+	if ok, err := follows.Follow(ctx, &models.Follow{FollowerID: "u", FolloweeID: "a"}, 2); err != nil || ok {
+		t.Fatalf("repeat follow = %v, %v", ok, err)
+	}
+	if _, following, _ := follows.Counts(ctx, "u"); following != 2 {
+		t.Fatalf("following = %d, want 2", following)
+	}
+}
+
+func TestMemoryUnlikeFloorsAtZero(t *testing.T) {
+	ctx := context.Background()
+	posts := NewMemoryRepositories().Posts
+	p := models.Post{UserID: "a", LikedBy: []string{"u"}, Likes: 0}
+	if err := posts.Create(ctx, &p); err != nil {
+		t.Fatal(err)
+	}
+	got, changed, err := posts.Unlike(ctx, p.ID.Hex(), "u")
+	if err != nil || !changed || got.Likes != 0 || len(got.LikedBy) != 0 {
+		t.Fatalf("Unlike = %+v, %v, %v", got, changed, err)
+	}
+}
@@ -0,0 +1,1233 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"finalapp/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoRepositories returns repositories backed by the given database.
+func NewMongoRepositories(db *mongo.Database) Repositories {
+	return Repositories{
+		Users:    &mongoUserRepo{coll: db.Collection("users")},
+		Posts:    &mongoPostRepo{coll: db.Collection("posts")},
+		Comments: &mongoCommentRepo{coll: db.Collection("comments")},
+		Tokens:   &mongoTokenRepo{coll: db.Collection("refresh_tokens")},
+
+		AccountTokens: &mongoAccountTokenRepo{coll: db.Collection("account_tokens")},
+		LoginAttempts: &mongoLoginAttemptRepo{coll: db.Collection("login_attempts")},
+		Delegations:   &mongoDelegationRepo{coll: db.Collection("delegations")},
+		Audit:         &mongoAuditRepo{coll: db.Collection("audit_log")},
+		Devices:       &mongoDeviceRepo{coll: db.Collection("devices")},
+		OIDCSessions:  &mongoOIDCSessionRepo{coll: db.Collection("oidc_sessions")},
+		Trends:        &mongoTrendRepo{coll: db.Collection("trending_tags")},
+		Taxonomy:      &mongoTaxonomyRepo{coll: db.Collection("tag_taxonomy")},
+		Follows:       &mongoFollowRepo{coll: db.Collection("follows")},
+	}
+}
+
+// EnsureIndexes creates the indexes the repositories rely on. It is safe to
+// call on every start; existing indexes are left untouched.
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "external_logins.issuer", Value: 1}, {Key: "external_logins.subject", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("posts").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "likes", Value: -1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "section", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "section", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		// The following feed asks for userid $in the followed users; each
+		// author's posts come off this index already sorted and are merged.
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		// A collection can have only one text index; it backs Search.
+		{
+			Keys: bson.D{{Key: "content", Value: "text"}, {Key: "tags", Value: "text"}, {Key: "username", Value: "text"}},
+			Options: options.Index().SetName("posts_text").SetWeights(bson.D{
+				{Key: "tags", Value: searchWeightTags},
+				{Key: "username", Value: searchWeightUserName},
+				{Key: "content", Value: searchWeightContent},
+			}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("comments").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "parent_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("refresh_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		// Expired tokens are purged by MongoDB's TTL monitor.
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("account_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("login_attempts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("delegations").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "elder_id", Value: 1}, {Key: "caregiver_id", Value: 1}}},
+		{Keys: bson.D{{Key: "caregiver_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("audit_log").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "elder_id", Value: 1}, {Key: "at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("devices").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "key_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("oidc_sessions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "state_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("follows").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "follower_id", Value: 1}, {Key: "followee_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "follower_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "followee_id", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	return err
+}
+
+type mongoUserRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoUserRepo) Create(ctx context.Context, user *models.User) error {
+	if _, err := r.FindByEmail(ctx, user.Email); err == nil {
+		return ErrDuplicate
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if user.LikedPosts == nil {
+		user.LikedPosts = []string{}
+	}
+	res, err := r.coll.InsertOne(ctx, user)
+	if err != nil {
+		// A concurrent sign-up can pass the lookup above; the unique email
+		// index stops the second insert.
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	user.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoUserRepo) FindByID(ctx context.Context, id string) (*models.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *mongoUserRepo) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+func (r *mongoUserRepo) UpdateProfile(ctx context.Context, id string, p models.ProfileUpdateRequest, at time.Time) (*models.User, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	update := bson.M{"$set": bson.M{
+		"name":            p.Name,
+		"bio":             p.Bio,
+		"location":        p.Location,
+		"profile_picture": p.ProfilePicture,
+		"updated_at":      at,
+	}}
+	var user models.User
+	err = r.coll.FindOneAndUpdate(ctx, bson.M{"_id": oid}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepo) SetPasswordHash(ctx context.Context, id, hash string) error {
+	return r.set(ctx, id, bson.M{"password_hash": hash, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepo) LinkNeighbour(ctx context.Context, id, neighbourID, role string) error {
+	return r.set(ctx, id, bson.M{"neighbour_id": neighbourID, "role": role, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepo) SetRole(ctx context.Context, id, role string) error {
+	return r.set(ctx, id, bson.M{"role": role, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepo) SetEmailVerified(ctx context.Context, id string) error {
+	return r.set(ctx, id, bson.M{"email_verified": true, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepo) FindByExternalLogin(ctx context.Context, issuer, subject string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"external_logins": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": subject}}})
+}
+
+func (r *mongoUserRepo) AddExternalLogin(ctx context.Context, id string, login models.ExternalLogin) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	update := bson.M{"$addToSet": bson.M{"external_logins": login}, "$set": bson.M{"updated_at": time.Now()}}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepo) SetTwoFactor(ctx context.Context, id string, tf *models.TwoFactor) error {
+	if tf == nil {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return ErrNotFound
+		}
+		update := bson.M{"$unset": bson.M{"two_factor": ""}, "$set": bson.M{"updated_at": time.Now()}}
+		res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, update)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return ErrNotFound
+		}
+		return nil
+	}
+	return r.set(ctx, id, bson.M{"two_factor": tf, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepo) UseTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, ErrNotFound
+	}
+	filter := bson.M{"_id": oid, "two_factor.enabled": true, "two_factor.last_step": bson.M{"$lt": step}}
+	res, err := r.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"two_factor.last_step": step}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *mongoUserRepo) UseRecoveryCode(ctx context.Context, id, hash string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, ErrNotFound
+	}
+	filter := bson.M{"_id": oid, "two_factor.enabled": true, "two_factor.recovery_codes": hash}
+	res, err := r.coll.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"two_factor.recovery_codes": hash}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *mongoUserRepo) set(ctx context.Context, id string, fields bson.M) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepo) SetLikedPost(ctx context.Context, userID, postID string, liked bool) error {
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrNotFound
+	}
+	// Rebuilt in a pipeline rather than with $addToSet/$pull so accounts
+	// stored with a null liked_posts are updated too.
+	others := bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$liked_posts", bson.A{}}},
+		"cond":  bson.M{"$ne": bson.A{"$$this", postID}},
+	}}
+	var likedPosts interface{} = others
+	if liked {
+		likedPosts = bson.M{"$concatArrays": bson.A{others, bson.A{postID}}}
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.A{bson.M{"$set": bson.M{"liked_posts": likedPosts}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepo) FindByIDs(ctx context.Context, ids []string) ([]models.User, error) {
+	oids := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			oids = append(oids, oid)
+		}
+	}
+	if len(oids) == 0 {
+		return nil, nil
+	}
+	cur, err := r.coll.Find(ctx, bson.M{"_id": bson.M{"$in": oids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var users []models.User
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *mongoUserRepo) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	var user models.User
+	if err := r.coll.FindOne(ctx, filter).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+type mongoPostRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoPostRepo) Create(ctx context.Context, post *models.Post) error {
+	// Nil slices would be stored as null, which array updates reject.
+	if post.LikedBy == nil {
+		post.LikedBy = []string{}
+	}
+	if post.Tags == nil {
+		post.Tags = []string{}
+	}
+	res, err := r.coll.InsertOne(ctx, post)
+	if err != nil {
+		return err
+	}
+	post.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoPostRepo) FindByID(ctx context.Context, id string) (*models.Post, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var post models.Post
+	if err := r.coll.FindOne(ctx, live(bson.M{"_id": oid})).Decode(&post); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (r *mongoPostRepo) UpdateContent(ctx context.Context, id, content string, tags []string, at time.Time) (*models.Post, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	update := bson.M{"$set": bson.M{"content": content, "tags": tags, "updated_at": at}}
+	var post models.Post
+	err = r.coll.FindOneAndUpdate(ctx, live(bson.M{"_id": oid}), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&post)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (r *mongoPostRepo) SoftDelete(ctx context.Context, id string, at time.Time) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx, live(bson.M{"_id": oid}), bson.M{"$set": bson.M{"deleted_at": at, "updated_at": at}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// live restricts filter to posts that have not been soft-deleted. A null
+// match also covers documents written before deleted_at existed.
+func live(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// Like and Unlike guard the update with a liked_by condition so that the
+// counter and the array change together in one atomic document update.
+func (r *mongoPostRepo) Like(ctx context.Context, postID, userID string) (*models.Post, bool, error) {
+	// A pipeline update, because posts written before liked_by was always
+	// initialised hold null there and $addToSet refuses non-arrays.
+	return r.updateLike(ctx, postID,
+		bson.M{"liked_by": bson.M{"$ne": userID}},
+		bson.A{bson.M{"$set": bson.M{
+			"liked_by": bson.M{"$concatArrays": bson.A{bson.M{"$ifNull": bson.A{"$liked_by", bson.A{}}}, bson.A{userID}}},
+			"likes":    bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$likes", 0}}, 1}},
+		}}})
+}
+
+func (r *mongoPostRepo) Unlike(ctx context.Context, postID, userID string) (*models.Post, bool, error) {
+	// Legacy posts can count fewer likes than liked_by holds, so the count
+	// stops at zero.
+	return r.updateLike(ctx, postID,
+		bson.M{"liked_by": userID},
+		bson.A{bson.M{"$set": bson.M{
+			"liked_by": bson.M{"$filter": bson.M{"input": "$liked_by", "cond": bson.M{"$ne": bson.A{"$$this", userID}}}},
+			"likes":    bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{bson.M{"$ifNull": bson.A{"$likes", 0}}, 1}}}},
+		}}})
+}
+
+func (r *mongoPostRepo) updateLike(ctx context.Context, postID string, cond bson.M, update interface{}) (*models.Post, bool, error) {
+	oid, err := primitive.ObjectIDFromHex(postID)
+	if err != nil {
+		return nil, false, ErrNotFound
+	}
+	cond["_id"] = oid
+	cond = live(cond)
+	var post models.Post
+	err = r.coll.FindOneAndUpdate(ctx, cond, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&post)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Either the post does not exist or the like state already matches.
+		p, err := r.FindByID(ctx, postID)
+		return p, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &post, true, nil
+}
+
+func (r *mongoPostRepo) AddComments(ctx context.Context, postID string, delta int) error {
+	oid, err := primitive.ObjectIDFromHex(postID)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx, live(bson.M{"_id": oid}), bson.M{"$inc": bson.M{"comment_count": delta}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoPostRepo) Feed(ctx context.Context, q FeedQuery) (FeedPage, error) {
+	q = q.normalize()
+	cur, err := decodeCursor(q.Sort, q.Cursor)
+	if err != nil {
+		return FeedPage{}, err
+	}
+	filter := bson.M{}
+	if cur != nil {
+		filter = cursorFilter(cur)
+	}
+	filter = live(filter)
+	if q.Section != "" {
+		filter["section"] = q.Section
+	}
+	if q.UserID != "" {
+		filter["userid"] = q.UserID
+	} else if q.Authors != nil {
+		filter["userid"] = bson.M{"$in": q.Authors}
+	}
+	if q.Tag != "" {
+		filter["tags"] = q.Tag
+	}
+	sort := bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+	if q.Sort == SortMostLiked {
+		sort = append(bson.D{{Key: "likes", Value: -1}}, sort...)
+	}
+	opts := options.Find().SetSort(sort).SetLimit(int64(q.Limit + 1))
+	posts, err := r.find(ctx, filter, opts)
+	if err != nil {
+		return FeedPage{}, err
+	}
+	return page(q.Sort, posts, q.Limit), nil
+}
+
+func (r *mongoPostRepo) Recent(ctx context.Context, section string, since, until time.Time, limit int) ([]models.Post, error) {
+	filter := live(bson.M{"created_at": bson.M{"$gt": since, "$lte": until}})
+	if section != "" {
+		filter["section"] = section
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(limit))
+	return r.find(ctx, filter, opts)
+}
+
+func (r *mongoPostRepo) FindByIDs(ctx context.Context, ids []string) ([]models.Post, error) {
+	oids := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			oids = append(oids, oid)
+		}
+	}
+	if len(oids) == 0 {
+		return nil, nil
+	}
+	return r.find(ctx, live(bson.M{"_id": bson.M{"$in": oids}}))
+}
+
+func (r *mongoPostRepo) Search(ctx context.Context, q SearchQuery) (SearchPage, error) {
+	q, offset, err := q.normalize()
+	if err != nil {
+		return SearchPage{}, err
+	}
+	filter := live(bson.M{"$text": bson.M{"$search": q.Text}})
+	if q.Section != "" {
+		filter["section"] = q.Section
+	}
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(q.Limit + 1))
+	cur, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	defer cur.Close(ctx)
+	var docs []struct {
+		models.Post `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return SearchPage{}, err
+	}
+	hits := make([]SearchHit, len(docs))
+	for i, d := range docs {
+		hits[i] = SearchHit{Post: d.Post, Score: d.Score}
+	}
+	return searchPage(hits, offset, q.Limit), nil
+}
+
+func (r *mongoPostRepo) TagActivity(ctx context.Context, since, until time.Time) ([]models.TagActivity, error) {
+	cur, err := r.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: live(bson.M{"created_at": bson.M{"$gte": since, "$lt": until}})}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$tags",
+			"posts":    bson.M{"$sum": 1},
+			"likes":    bson.M{"$sum": "$likes"},
+			"comments": bson.M{"$sum": "$comment_count"},
+			"authors":  bson.M{"$addToSet": "$userid"},
+		}}},
+		{{Key: "$addFields", Value: bson.M{"authors": bson.M{"$size": "$authors"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []models.TagActivity
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *mongoPostRepo) SetTags(ctx context.Context, id string, tags []string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx, live(bson.M{"_id": oid}), bson.M{"$set": bson.M{"tags": tags}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoPostRepo) CountByTag(ctx context.Context, tag string) (int, error) {
+	n, err := r.coll.CountDocuments(ctx, live(bson.M{"tags": tag}))
+	return int(n), err
+}
+
+// cursorFilter matches posts that sort strictly after the cursor position.
+func cursorFilter(c *feedCursor) bson.M {
+	if c.Sort == SortMostLiked {
+		return bson.M{"$or": bson.A{
+			bson.M{"likes": bson.M{"$lt": c.Likes}},
+			bson.M{"likes": c.Likes, "created_at": bson.M{"$lt": c.CreatedAt}},
+			bson.M{"likes": c.Likes, "created_at": c.CreatedAt, "_id": bson.M{"$lt": c.ID}},
+		}}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"created_at": bson.M{"$lt": c.CreatedAt}},
+		bson.M{"created_at": c.CreatedAt, "_id": bson.M{"$lt": c.ID}},
+	}}
+}
+
+func (r *mongoPostRepo) ListByUser(ctx context.Context, userID, section string) ([]models.Post, error) {
+	filter := live(bson.M{"userid": userID})
+	if section != "" {
+		filter["section"] = section
+	}
+	return r.find(ctx, filter)
+}
+
+func (r *mongoPostRepo) CountBySection(ctx context.Context, userID string) (map[string]int, error) {
+	cur, err := r.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: live(bson.M{"userid": userID})}},
+		{{Key: "$group", Value: bson.M{"_id": "$section", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var rows []struct {
+		Section string `bson:"_id"`
+		Count   int    `bson:"count"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, row := range rows {
+		counts[row.Section] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *mongoPostRepo) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.Post, error) {
+	cur, err := r.coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var posts []models.Post
+	if err := cur.All(ctx, &posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+type mongoCommentRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoCommentRepo) Create(ctx context.Context, comment *models.Comment) error {
+	res, err := r.coll.InsertOne(ctx, comment)
+	if err != nil {
+		return err
+	}
+	comment.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoCommentRepo) FindByID(ctx context.Context, id string) (*models.Comment, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var c models.Comment
+	if err := r.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&c); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *mongoCommentRepo) ListByPost(ctx context.Context, postID string) ([]models.Comment, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := r.coll.Find(ctx, bson.M{"post_id": postID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var comments []models.Comment
+	if err := cur.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *mongoCommentRepo) UpdateContent(ctx context.Context, id, content string, at time.Time) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"content": content, "updated_at": at}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoCommentRepo) Delete(ctx context.Context, id string) (int, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, ErrNotFound
+	}
+	res, err := r.coll.DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"_id": oid}, bson.M{"parent_id": id}}})
+	if err != nil {
+		return 0, err
+	}
+	if res.DeletedCount == 0 {
+		return 0, ErrNotFound
+	}
+	return int(res.DeletedCount), nil
+}
+
+type mongoTokenRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoTokenRepo) CreateRefresh(ctx context.Context, token *models.RefreshToken) error {
+	res, err := r.coll.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+	token.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoTokenRepo) FindRefresh(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	if err := r.coll.FindOne(ctx, bson.M{"hash": hash}).Decode(&t); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *mongoTokenRepo) MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": id, "used_at": nil}, bson.M{"$set": bson.M{"used_at": at}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *mongoTokenRepo) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := r.coll.UpdateMany(ctx, bson.M{"family_id": familyID, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": at}})
+	return err
+}
+
+func (r *mongoTokenRepo) RevokeUser(ctx context.Context, userID string, at time.Time) error {
+	_, err := r.coll.UpdateMany(ctx, bson.M{"user_id": userID, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": at}})
+	return err
+}
+
+func (r *mongoTokenRepo) FamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	n, err := r.coll.CountDocuments(ctx, bson.M{"family_id": familyID, "revoked_at": bson.M{"$ne": nil}}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+type mongoAccountTokenRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoAccountTokenRepo) Create(ctx context.Context, token *models.AccountToken) error {
+	res, err := r.coll.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+	token.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoAccountTokenRepo) Consume(ctx context.Context, hash, purpose string, at time.Time) (*models.AccountToken, error) {
+	filter := bson.M{"hash": hash, "purpose": purpose, "used_at": nil, "expires_at": bson.M{"$gt": at}}
+	var t models.AccountToken
+	err := r.coll.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"used_at": at}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&t)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *mongoAccountTokenRepo) InvalidateUser(ctx context.Context, userID, purpose string, at time.Time) error {
+	_, err := r.coll.UpdateMany(ctx, bson.M{"user_id": userID, "purpose": purpose, "used_at": nil}, bson.M{"$set": bson.M{"used_at": at}})
+	return err
+}
+
+type mongoLoginAttemptRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoLoginAttemptRepo) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	var a models.LoginAttempt
+	if err := r.coll.FindOne(ctx, bson.M{"_id": key}).Decode(&a); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *mongoLoginAttemptRepo) RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"last_failure": at, "expires_at": at.Add(window)},
+	}
+	var a models.LoginAttempt
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := r.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *mongoLoginAttemptRepo) Lock(ctx context.Context, key string, until time.Time, window time.Duration) error {
+	update := bson.M{"$set": bson.M{"failures": 0, "locked_until": until, "expires_at": until.Add(window)}}
+	_, err := r.coll.UpdateOne(ctx, bson.M{"_id": key}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *mongoLoginAttemptRepo) Clear(ctx context.Context, key string) error {
+	_, err := r.coll.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+type mongoDelegationRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoDelegationRepo) Grant(ctx context.Context, d *models.Delegation) error {
+	filter := bson.M{"elder_id": d.ElderID, "caregiver_id": d.CaregiverID, "revoked_at": nil}
+	update := bson.M{
+		"$set":         bson.M{"scopes": d.Scopes, "updated_at": d.UpdatedAt},
+		"$setOnInsert": bson.M{"created_at": d.UpdatedAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return r.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(d)
+}
+
+func (r *mongoDelegationRepo) Active(ctx context.Context, elderID, caregiverID string) (*models.Delegation, error) {
+	var d models.Delegation
+	err := r.coll.FindOne(ctx, bson.M{"elder_id": elderID, "caregiver_id": caregiverID, "revoked_at": nil}).Decode(&d)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *mongoDelegationRepo) Revoke(ctx context.Context, elderID, caregiverID string, at time.Time) error {
+	filter := bson.M{"elder_id": elderID, "caregiver_id": caregiverID, "revoked_at": nil}
+	res, err := r.coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at, "updated_at": at}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoDelegationRepo) ListByElder(ctx context.Context, elderID string) ([]models.Delegation, error) {
+	return r.list(ctx, bson.M{"elder_id": elderID, "revoked_at": nil})
+}
+
+func (r *mongoDelegationRepo) ListByCaregiver(ctx context.Context, caregiverID string) ([]models.Delegation, error) {
+	return r.list(ctx, bson.M{"caregiver_id": caregiverID, "revoked_at": nil})
+}
+
+func (r *mongoDelegationRepo) list(ctx context.Context, filter bson.M) ([]models.Delegation, error) {
+	cur, err := r.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []models.Delegation{}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type mongoAuditRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoAuditRepo) Record(ctx context.Context, entry *models.AuditEntry) error {
+	res, err := r.coll.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+	entry.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoAuditRepo) ListByElder(ctx context.Context, elderID string, limit int) ([]models.AuditEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(limit))
+	cur, err := r.coll.Find(ctx, bson.M{"elder_id": elderID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []models.AuditEntry{}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type mongoDeviceRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoDeviceRepo) Create(ctx context.Context, device *models.Device) error {
+	res, err := r.coll.InsertOne(ctx, device)
+	if err != nil {
+		return err
+	}
+	device.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoDeviceRepo) FindByID(ctx context.Context, id string) (*models.Device, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *mongoDeviceRepo) FindByKeyHash(ctx context.Context, hash string) (*models.Device, error) {
+	return r.findOne(ctx, bson.M{"key_hash": hash})
+}
+
+func (r *mongoDeviceRepo) List(ctx context.Context) ([]models.Device, error) {
+	cur, err := r.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []models.Device{}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *mongoDeviceRepo) Update(ctx context.Context, id, name string, elderIDs, scopes []string, at time.Time) (*models.Device, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	update := bson.M{"$set": bson.M{"name": name, "elder_ids": elderIDs, "scopes": scopes, "updated_at": at}}
+	var d models.Device
+	err = r.coll.FindOneAndUpdate(ctx, bson.M{"_id": oid}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&d)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *mongoDeviceRepo) SetKey(ctx context.Context, id, hash, prefix string, at time.Time) error {
+	return r.set(ctx, id, bson.M{"key_hash": hash, "key_prefix": prefix, "updated_at": at})
+}
+
+func (r *mongoDeviceRepo) Revoke(ctx context.Context, id string, at time.Time) error {
+	return r.set(ctx, id, bson.M{"revoked_at": at, "updated_at": at})
+}
+
+func (r *mongoDeviceRepo) Touch(ctx context.Context, id string, at time.Time, ip string) error {
+	return r.set(ctx, id, bson.M{"last_seen_at": at, "last_seen_ip": ip})
+}
+
+func (r *mongoDeviceRepo) set(ctx context.Context, id string, fields bson.M) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoDeviceRepo) findOne(ctx context.Context, filter bson.M) (*models.Device, error) {
+	var d models.Device
+	if err := r.coll.FindOne(ctx, filter).Decode(&d); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+type mongoOIDCSessionRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoOIDCSessionRepo) Create(ctx context.Context, session *models.OIDCSession) error {
+	res, err := r.coll.InsertOne(ctx, session)
+	if err != nil {
+		return err
+	}
+	session.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoOIDCSessionRepo) Consume(ctx context.Context, stateHash string, at time.Time) (*models.OIDCSession, error) {
+	var s models.OIDCSession
+	err := r.coll.FindOneAndDelete(ctx, bson.M{"state_hash": stateHash, "expires_at": bson.M{"$gt": at}}).Decode(&s)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+type mongoTrendRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoTrendRepo) Save(ctx context.Context, snap *models.TrendingSnapshot) error {
+	_, err := r.coll.ReplaceOne(ctx, bson.M{"_id": snap.Window}, snap, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *mongoTrendRepo) Latest(ctx context.Context, window string) (*models.TrendingSnapshot, error) {
+	var snap models.TrendingSnapshot
+	if err := r.coll.FindOne(ctx, bson.M{"_id": window}).Decode(&snap); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &snap, nil
+}
+
+type mongoTaxonomyRepo struct {
+	coll *mongo.Collection
+}
+
+func (r *mongoTaxonomyRepo) List(ctx context.Context) ([]models.TagDefinition, error) {
+	cur, err := r.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var defs []models.TagDefinition
+	if err := cur.All(ctx, &defs); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+func (r *mongoTaxonomyRepo) Put(ctx context.Context, def *models.TagDefinition) error {
+	_, err := r.coll.ReplaceOne(ctx, bson.M{"_id": def.Tag}, def, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *mongoTaxonomyRepo) Delete(ctx context.Context, tag string) error {
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": tag})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type mongoFollowRepo struct {
+	coll *mongo.Collection
+}
+
+// Follow checks the limit after inserting: each insert counts every follow
+// made before its count, so concurrent follows cannot all slip under the
+// limit. An insert that finds the follower over it is undone, and racing
+// follows near the limit may all be refused.
+func (r *mongoFollowRepo) Follow(ctx context.Context, f *models.Follow, limit int) (bool, error) {
+	filter := bson.M{"follower_id": f.FollowerID, "followee_id": f.FolloweeID}
+	update := bson.M{"$setOnInsert": bson.M{"created_at": f.CreatedAt}}
+	res, err := r.coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		// Two concurrent upserts can both miss; the unique index stops the
+		// second, which is then simply a follow that already exists.
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if res.UpsertedID == nil {
+		return false, nil
+	}
+	id := res.UpsertedID.(primitive.ObjectID)
+	n, err := r.coll.CountDocuments(ctx, bson.M{"follower_id": f.FollowerID})
+	if err == nil && n <= int64(limit) {
+		f.ID = id
+		return true, nil
+	}
+	if _, delErr := r.coll.DeleteOne(ctx, bson.M{"_id": id}); delErr != nil && err == nil {
+		err = delErr
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, ErrLimitReached
+}
+
+func (r *mongoFollowRepo) Unfollow(ctx context.Context, followerID, followeeID string) (bool, error) {
+	res, err := r.coll.DeleteOne(ctx, bson.M{"follower_id": followerID, "followee_id": followeeID})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+func (r *mongoFollowRepo) IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error) {
+	n, err := r.coll.CountDocuments(ctx, bson.M{"follower_id": followerID, "followee_id": followeeID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *mongoFollowRepo) Followers(ctx context.Context, userID string, q FollowQuery) (FollowPage, error) {
+	return r.page(ctx, bson.M{"followee_id": userID}, q)
+}
+
+func (r *mongoFollowRepo) Following(ctx context.Context, userID string, q FollowQuery) (FollowPage, error) {
+	return r.page(ctx, bson.M{"follower_id": userID}, q)
+}
+
+func (r *mongoFollowRepo) page(ctx context.Context, filter bson.M, q FollowQuery) (FollowPage, error) {
+	q, after, err := q.normalize()
+	if err != nil {
+		return FollowPage{}, err
+	}
+	if after != nil {
+		filter["_id"] = bson.M{"$lt": *after}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(q.Limit + 1))
+	cur, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return FollowPage{}, err
+	}
+	defer cur.Close(ctx)
+	follows := []models.Follow{}
+	if err := cur.All(ctx, &follows); err != nil {
+		return FollowPage{}, err
+	}
+	return followPage(follows, q.Limit), nil
+}
+
+func (r *mongoFollowRepo) FollowingIDs(ctx context.Context, userID string) ([]string, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 0, "followee_id": 1})
+	cur, err := r.coll.Find(ctx, bson.M{"follower_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var follows []models.Follow
+	if err := cur.All(ctx, &follows); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(follows))
+	for i, f := range follows {
+		ids[i] = f.FolloweeID
+	}
+	return ids, nil
+}
+
+func (r *mongoFollowRepo) Counts(ctx context.Context, userID string) (followers, following int, err error) {
+	n, err := r.coll.CountDocuments(ctx, bson.M{"followee_id": userID})
+	if err != nil {
+		return 0, 0, err
+	}
+	m, err := r.coll.CountDocuments(ctx, bson.M{"follower_id": userID})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(n), int(m), nil
+}
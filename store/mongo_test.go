@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"finalapp/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// testDB connects to the MongoDB named by MONGO_TEST_URI and returns a fresh
+// database that is dropped when the test ends. Without the variable the test
+// is skipped.
+func testDB(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+	ctx := context.Background()
+	db, err := Init(ctx, uri, fmt.Sprintf("finalapp_test_%d", time.Now().UnixNano()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = db.Client().Disconnect(ctx)
+	})
+	if err := EnsureIndexes(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMongoLikeNewPost(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMongoRepositories(testDB(t))
+	user := models.User{Email: "a@example.com", Name: "A"}
+	if err := repos.Users.Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	post := models.Post{UserID: "author", Section: models.SectionRemedies, CreatedAt: time.Now()}
+	if err := repos.Posts.Create(ctx, &post); err != nil {
+		t.Fatal(err)
+	}
+	uid, pid := user.ID.Hex(), post.ID.Hex()
+
+	got, changed, err := repos.Posts.Like(ctx, pid, uid)
+	if err != nil || !changed || got.Likes != 1 || len(got.LikedBy) != 1 {
+		t.Fatalf("Like = %+v, %v, %v", got, changed, err)
+	}
+	if got, changed, err = repos.Posts.Like(ctx, pid, uid); err != nil || changed || got.Likes != 1 {
+		t.Fatalf("second Like = %+v, %v, %v", got, changed, err)
+	}
+	if err := repos.Users.SetLikedPost(ctx, uid, pid, true); err != nil {
+		t.Fatal(err)
+	}
+	u, err := repos.Users.FindByID(ctx, uid)
+	if err != nil || len(u.LikedPosts) != 1 || u.LikedPosts[0] != pid {
+		t.Fatalf("liked_posts = %v, %v", u.LikedPosts, err)
+	}
+
+	if got, changed, err = repos.Posts.Unlike(ctx, pid, uid); err != nil || !changed || got.Likes != 0 || len(got.LikedBy) != 0 {
+		t.Fatalf("Unlike = %+v, %v, %v", got, changed, err)
+	}
+	if err := repos.Users.SetLikedPost(ctx, uid, pid, false); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ = repos.Users.FindByID(ctx, uid); len(u.LikedPosts) != 0 {
+		t.Fatalf("liked_posts after unlike = %v", u.LikedPosts)
+	}
+}
+
+// Documents written before the slices were initialised hold null.
+func TestMongoLikeNullArrays(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	repos := NewMongoRepositories(db)
+	pid, uid := primitive.NewObjectID(), primitive.NewObjectID()
+	if _, err := db.Collection("posts").InsertOne(ctx, bson.M{"_id": pid, "liked_by": nil, "likes": 0, "created_at": time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Collection("users").InsertOne(ctx, bson.M{"_id": uid, "email": "b@example.com", "liked_posts": nil}); err != nil {
+		t.Fatal(err)
+	}
+	got, changed, err := repos.Posts.Like(ctx, pid.Hex(), uid.Hex())
+	if err != nil || !changed || got.Likes != 1 || len(got.LikedBy) != 1 {
+		t.Fatalf("Like = %+v, %v, %v", got, changed, err)
+	}
+	if err := repos.Users.SetLikedPost(ctx, uid.Hex(), pid.Hex(), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Users.SetLikedPost(ctx, uid.Hex(), pid.Hex(), true); err != nil {
+		t.Fatal(err)
+	}
+	u, err := repos.Users.FindByID(ctx, uid.Hex())
+	if err != nil || len(u.LikedPosts) != 1 {
+		t.Fatalf("liked_posts = %v, %v", u.LikedPosts, err)
+	}
+}
+
+// Concurrent sign-ups for one address can all pass the email lookup; the
+// losers must still see ErrDuplicate.
+func TestMongoUserCreateRace(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMongoRepositories(testDB(t))
+	errs := make(chan error, 8)
+	for range cap(errs) {
+		go func() {
+			errs <- repos.Users.Create(ctx, &models.User{Email: "race@example.com"})
+		}()
+	}
+	created := 0
+	for range cap(errs) {
+		switch err := <-errs; {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrDuplicate):
+			t.Errorf("Create err = %v, want ErrDuplicate", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d accounts created, want 1", created)
+	}
+}
+
+// Concurrent follows near the limit must not take the count past it.
+func TestMongoFollowLimitRace(t *testing.T) {
+	ctx := context.Background()
+	follows := NewMongoRepositories(testDB(t)).Follows
+	const limit = 3
+	errs := make(chan error, 10)
+	for i := range cap(errs) {
+		go func() {
+			_, err := follows.Follow(ctx, &models.Follow{FollowerID: "u", FolloweeID: fmt.Sprint(i), CreatedAt: time.Now()}, limit)
+			errs <- err
+		}()
+	}
+	for range cap(errs) {
+		if err := <-errs; err != nil && !errors.Is(err, ErrLimitReached) {
+			t.Fatal(err)
+		}
+	}
+	if _, following, err := follows.Counts(ctx, "u"); err != nil || following > limit {
+		t.Fatalf("following = %d, %v; want at most %d", following, err, limit)
+	}
+}
+
+// A legacy post can list a liker while counting no likes; unliking must not
+// take the count below zero.
+func TestMongoUnlikeFloorsAtZero(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	repos := NewMongoRepositories(db)
+	pid := primitive.NewObjectID()
+	if _, err := db.Collection("posts").InsertOne(ctx, bson.M{"_id": pid, "liked_by": bson.A{"u", "v"}, "likes": 0, "created_at": time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	got, changed, err := repos.Posts.Unlike(ctx, pid.Hex(), "u")
+	if err != nil || !changed || got.Likes != 0 || len(got.LikedBy) != 1 || got.LikedBy[0] != "v" {
+		t.Fatalf("Unlike = %+v, %v, %v", got, changed, err)
+	}
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"finalapp/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNotFound is returned by repositories when no document matches.
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicate is returned when a unique field (e.g. email) already exists.
+var ErrDuplicate = errors.New("duplicate")
+
+// ErrLimitReached is returned when a write would take a capped count past
+// its limit.
+var ErrLimitReached = errors.New("limit reached")
+
+type UserRepository interface {
+	// Create inserts the user and sets its ID.
+	Create(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, id string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// UpdateProfile overwrites the editable profile fields and returns the
+	// updated user.
+	UpdateProfile(ctx context.Context, id string, p models.ProfileUpdateRequest, at time.Time) (*models.User, error)
+	// SetPasswordHash replaces the stored password hash.
+	SetPasswordHash(ctx context.Context, id, hash string) error
+	// LinkNeighbour records the user's Neighbour network profile and role.
+	LinkNeighbour(ctx context.Context, id, neighbourID, role string) error
+	SetRole(ctx context.Context, id, role string) error
+	// SetEmailVerified marks the user's email address as confirmed.
+	SetEmailVerified(ctx context.Context, id string) error
+	// FindByExternalLogin returns the user linked to the issuer and subject.
+	FindByExternalLogin(ctx context.Context, issuer, subject string) (*models.User, error)
+	AddExternalLogin(ctx context.Context, id string, login models.ExternalLogin) error
+	// SetTwoFactor replaces the user's two-factor enrolment; nil removes it.
+	SetTwoFactor(ctx context.Context, id string, tf *models.TwoFactor) error
+	// UseTOTPStep atomically records step as the last accepted TOTP step of
+	// an enabled enrolment. It reports false if step is not newer than the
+	// last one, which means the code was replayed.
+	UseTOTPStep(ctx context.Context, id string, step int64) (bool, error)
+	// UseRecoveryCode atomically removes the recovery code with this hash
+	// and reports whether it was there.
+	UseRecoveryCode(ctx context.Context, id, hash string) (bool, error)
+	// SetLikedPost adds or removes postID from the user's liked posts.
+	SetLikedPost(ctx context.Context, userID, postID string, liked bool) error
+	// FindByIDs returns the users with the given IDs, skipping unknown ones,
+	// in no particular order.
+	FindByIDs(ctx context.Context, ids []string) ([]models.User, error)
+}
+
+// PostRepository never returns soft-deleted posts; every lookup treats them
+// as missing.
+type PostRepository interface {
+	// Create inserts the post and sets its ID.
+	Create(ctx context.Context, post *models.Post) error
+	FindByID(ctx context.Context, id string) (*models.Post, error)
+	// UpdateContent replaces the post's content and tags and stamps UpdatedAt.
+	UpdateContent(ctx context.Context, id, content string, tags []string, at time.Time) (*models.Post, error)
+	// SoftDelete marks the post deleted so it drops out of feeds and counts.
+	SoftDelete(ctx context.Context, id string, at time.Time) error
+	// Like records userID as liking the post and returns the updated post.
+	// Liking twice is a no-op, so the counter never double counts; changed
+	// reports whether this call recorded the like.
+	Like(ctx context.Context, postID, userID string) (post *models.Post, changed bool, err error)
+	// Unlike reverses Like; unliking a post that is not liked is a no-op.
+	Unlike(ctx context.Context, postID, userID string) (post *models.Post, changed bool, err error)
+	// AddComments adjusts the post's denormalized comment count by delta.
+	AddComments(ctx context.Context, postID string, delta int) error
+	// Feed returns one page of posts in the requested sort order.
+	Feed(ctx context.Context, q FeedQuery) (FeedPage, error)
+	// Recent returns up to limit posts created after since and no later than
+	// until, newest first, optionally restricted to a section.
+	Recent(ctx context.Context, section string, since, until time.Time, limit int) ([]models.Post, error)
+	// FindByIDs returns the posts with the given IDs that exist, in no
+	// particular order.
+	FindByIDs(ctx context.Context, ids []string) ([]models.Post, error)
+	// Search returns one page of posts matching the query text in their
+	// content, tags or author name, most relevant first.
+	Search(ctx context.Context, q SearchQuery) (SearchPage, error)
+	// TagActivity sums, per tag, the posts created in [since, until) and
+	// their likes, comments and distinct authors.
+	TagActivity(ctx context.Context, since, until time.Time) ([]models.TagActivity, error)
+	// CountByTag returns how many posts carry the tag.
+	CountByTag(ctx context.Context, tag string) (int, error)
+	// SetTags replaces the post's tags without touching UpdatedAt.
+	SetTags(ctx context.Context, id string, tags []string) error
+	// CountBySection returns the number of the user's posts in each section.
+	CountBySection(ctx context.Context, userID string) (map[string]int, error)
+	// ListByUser returns the user's posts, optionally restricted to a section.
+	ListByUser(ctx context.Context, userID, section string) ([]models.Post, error)
+}
+
+type CommentRepository interface {
+	// Create inserts the comment and sets its ID.
+	Create(ctx context.Context, comment *models.Comment) error
+	FindByID(ctx context.Context, id string) (*models.Comment, error)
+	// ListByPost returns every comment on the post, oldest first.
+	ListByPost(ctx context.Context, postID string) ([]models.Comment, error)
+	UpdateContent(ctx context.Context, id, content string, at time.Time) error
+	// Delete removes the comment and its replies and reports how many
+	// comments were removed.
+	Delete(ctx context.Context, id string) (int, error)
+}
+
+type TokenRepository interface {
+	CreateRefresh(ctx context.Context, token *models.RefreshToken) error
+	FindRefresh(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// MarkUsed atomically flags the token as rotated. It reports false if the
+	// token had already been used, which signals reuse.
+	MarkUsed(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+	// RevokeFamily revokes every refresh token in the family.
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	// RevokeUser revokes every refresh token belonging to the user.
+	RevokeUser(ctx context.Context, userID string, at time.Time) error
+	FamilyRevoked(ctx context.Context, familyID string) (bool, error)
+}
+
+type AccountTokenRepository interface {
+	Create(ctx context.Context, token *models.AccountToken) error
+	// Consume atomically marks the unused, unexpired token with this hash and
+	// purpose as used and returns it. Anything else is ErrNotFound.
+	Consume(ctx context.Context, hash, purpose string, at time.Time) (*models.AccountToken, error)
+	// InvalidateUser marks every outstanding token of the user with this
+	// purpose as used.
+	InvalidateUser(ctx context.Context, userID, purpose string, at time.Time) error
+}
+
+// LoginAttemptRepository forgets a key once its ExpiresAt passes.
+type LoginAttemptRepository interface {
+	Find(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RecordFailure counts a failed sign-in at the given time, keeps the
+	// record for window after it, and returns the updated record.
+	RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*models.LoginAttempt, error)
+	// Lock locks key until the given time and restarts its failure count.
+	Lock(ctx context.Context, key string, until time.Time, window time.Duration) error
+	// Clear forgets key's failures and any lock.
+	Clear(ctx context.Context, key string) error
+}
+
+type DelegationRepository interface {
+	// Grant creates the elder's delegation to the caregiver, or replaces the
+	// scopes of the active one, and fills in d.
+	Grant(ctx context.Context, d *models.Delegation) error
+	// Active returns the unrevoked delegation from elder to caregiver.
+	Active(ctx context.Context, elderID, caregiverID string) (*models.Delegation, error)
+	Revoke(ctx context.Context, elderID, caregiverID string, at time.Time) error
+	// ListByElder and ListByCaregiver return active delegations only.
+	ListByElder(ctx context.Context, elderID string) ([]models.Delegation, error)
+	ListByCaregiver(ctx context.Context, caregiverID string) ([]models.Delegation, error)
+}
+
+type AuditRepository interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	// ListByElder returns up to limit entries about the elder, newest first.
+	ListByElder(ctx context.Context, elderID string, limit int) ([]models.AuditEntry, error)
+}
+
+type DeviceRepository interface {
+	// Create inserts the device and sets its ID.
+	Create(ctx context.Context, device *models.Device) error
+	FindByID(ctx context.Context, id string) (*models.Device, error)
+	// FindByKeyHash returns the device holding the key, revoked or not.
+	FindByKeyHash(ctx context.Context, hash string) (*models.Device, error)
+	// List returns every device, newest first.
+	List(ctx context.Context) ([]models.Device, error)
+	// Update replaces the device's name, elders and scopes.
+	Update(ctx context.Context, id, name string, elderIDs, scopes []string, at time.Time) (*models.Device, error)
+	// SetKey replaces the device's key.
+	SetKey(ctx context.Context, id, hash, prefix string, at time.Time) error
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// Touch records that the device was just used from ip.
+	Touch(ctx context.Context, id string, at time.Time, ip string) error
+}
+
+type OIDCSessionRepository interface {
+	Create(ctx context.Context, session *models.OIDCSession) error
+	// Consume deletes and returns the unexpired session for the state hash.
+	Consume(ctx context.Context, stateHash string, at time.Time) (*models.OIDCSession, error)
+}
+
+// TrendRepository stores the latest trending snapshot per window.
+type TrendRepository interface {
+	// Save replaces the snapshot for its window.
+	Save(ctx context.Context, snap *models.TrendingSnapshot) error
+	Latest(ctx context.Context, window string) (*models.TrendingSnapshot, error)
+}
+
+// TaxonomyRepository stores the canonical tag definitions.
+type TaxonomyRepository interface {
+	List(ctx context.Context) ([]models.TagDefinition, error)
+	// Put creates or replaces the definition of def.Tag.
+	Put(ctx context.Context, def *models.TagDefinition) error
+	Delete(ctx context.Context, tag string) error
+}
+
+// FollowRepository stores who follows whom.
+type FollowRepository interface {
+	// Follow records f and sets its ID. It reports false, leaving f as it
+	// was, if the follower already follows the followee, and returns
+	// ErrLimitReached if the follower already follows limit users.
+	Follow(ctx context.Context, f *models.Follow, limit int) (bool, error)
+	// Unfollow removes the follow and reports whether there was one.
+	Unfollow(ctx context.Context, followerID, followeeID string) (bool, error)
+	IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error)
+	// Followers returns a page of the follows of userID.
+	Followers(ctx context.Context, userID string, q FollowQuery) (FollowPage, error)
+	// Following returns a page of the follows made by userID.
+	Following(ctx context.Context, userID string, q FollowQuery) (FollowPage, error)
+	// FollowingIDs returns the IDs of every user userID follows.
+	FollowingIDs(ctx context.Context, userID string) ([]string, error)
+	// Counts returns how many users follow userID and how many it follows.
+	Counts(ctx context.Context, userID string) (followers, following int, err error)
+}
+
+// Repositories groups the repositories the handlers depend on.
+type Repositories struct {
+	Users    UserRepository
+	Posts    PostRepository
+	Comments CommentRepository
+	Tokens   TokenRepository
+
+	AccountTokens AccountTokenRepository
+	LoginAttempts LoginAttemptRepository
+	Delegations   DelegationRepository
+	Audit         AuditRepository
+	Devices       DeviceRepository
+	OIDCSessions  OIDCSessionRepository
+	Trends        TrendRepository
+	Taxonomy      TaxonomyRepository
+	Follows       FollowRepository
+}
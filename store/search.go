@@ -0,0 +1,150 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"finalapp/models"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 50
+	// MaxSearchOffset stops clients paging arbitrarily deep, which costs a
+	// skip over every earlier match.
+	MaxSearchOffset = 1000
+)
+
+// Field weights for relevance, shared by the MongoDB text index and the
+// in-memory search so both rank alike.
+const (
+	searchWeightTags     = 5
+	searchWeightUserName = 3
+	searchWeightContent  = 1
+)
+
+// SearchQuery is a full-text search over posts. Section is an optional
+// filter; Cursor is the NextCursor of the previous page.
+type SearchQuery struct {
+	Text    string
+	Section string
+	Limit   int
+	Cursor  string
+}
+
+// SearchHit is a matching post and its relevance; higher is better.
+type SearchHit struct {
+	Post  models.Post `json:"post"`
+	Score float64     `json:"score"`
+}
+
+// SearchPage is one page of hits, best first.
+type SearchPage struct {
+	Hits       []SearchHit `json:"results"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// searchCursor is an offset into the ranked hits. Relevance has no stable
+// keyset to page on, so search pages by offset.
+type searchCursor struct {
+	Offset int `json:"o"`
+}
+
+// normalize clamps the limit and decodes the cursor into an offset.
+func (q SearchQuery) normalize() (SearchQuery, int, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit > MaxSearchLimit {
+		q.Limit = MaxSearchLimit
+	}
+	if q.Cursor == "" {
+		return q, 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return q, 0, ErrInvalidCursor
+	}
+	var c searchCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Offset < 0 || c.Offset > MaxSearchOffset {
+		return q, 0, ErrInvalidCursor
+	}
+	return q, c.Offset, nil
+}
+
+// searchPage trims hits fetched with limit+1 and computes the next cursor.
+func searchPage(hits []SearchHit, offset, limit int) SearchPage {
+	if len(hits) <= limit || offset+limit > MaxSearchOffset {
+		if len(hits) > limit {
+			hits = hits[:limit]
+		}
+		return SearchPage{Hits: hits}
+	}
+	b, _ := json.Marshal(searchCursor{Offset: offset + limit})
+	return SearchPage{Hits: hits[:limit], NextCursor: base64.RawURLEncoding.EncodeToString(b)}
+}
+
+// stopWords are dropped from queries, as MongoDB's English text search does.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true,
+	"it": true, "my": true, "of": true, "on": true, "or": true, "the": true, "to": true,
+	"what": true, "with": true,
+}
+
+// SearchTerms splits text into the stemmed, lower-case terms a search
+// matches on, without stop words or duplicates.
+func SearchTerms(text string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, w := range searchWords(text) {
+		if stopWords[w] {
+			continue
+		}
+		if t := Stem(w); !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Stem crudely reduces an English word so "pains" matches "pain" and
+// "soothing" matches "soothe". It only needs to agree with itself.
+func Stem(w string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s", "e"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= 3 {
+			return w[:len(w)-len(suffix)]
+		}
+	}
+	return w
+}
+
+// searchScore is the in-memory stand-in for MongoDB's textScore: weighted
+// counts of matching words per field. Zero means no match.
+func searchScore(p models.Post, terms []string) float64 {
+	want := map[string]bool{}
+	for _, t := range terms {
+		want[t] = true
+	}
+	count := func(text string) float64 {
+		n := 0.0
+		for _, w := range searchWords(text) {
+			if want[Stem(w)] {
+				n++
+			}
+		}
+		return n
+	}
+	return searchWeightTags*count(strings.Join(p.Tags, " ")) +
+		searchWeightUserName*count(p.UserName) +
+		searchWeightContent*count(p.Content)
+}
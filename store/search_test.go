@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"finalapp/models"
+)
+
+func TestSearchTerms(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"What is the best remedy for a cold?", []string{"best", "remedy", "cold"}},
+		{"Ginger, GINGER and ginger!", []string{"ginger"}},
+		{"soothing pains", []string{"sooth", "pain"}},
+		{"the and of", nil},
+	} {
+		if got := SearchTerms(tc.in); !slices.Equal(got, tc.want) {
+			t.Errorf("SearchTerms(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"pains", "pain"},
+		{"pain", "pain"},
+		{"soothing", "sooth"},
+		{"soothe", "sooth"},
+		{"boiled", "boil"},
+		{"remedies", "remedi"},
+		// Too short to strip.
+		{"is", "is"},
+		{"uses", "use"},
+	} {
+		if got := Stem(tc.in); got != tc.want {
+			t.Errorf("Stem(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSearchScoreWeights(t *testing.T) {
+	terms := SearchTerms("ginger")
+	tag := searchScore(models.Post{Tags: []string{"#ginger"}}, terms)
+	name := searchScore(models.Post{UserName: "Ginger Rogers"}, terms)
+	content := searchScore(models.Post{Content: "Boil some ginger."}, terms)
+	if !(tag > name && name > content && content > 0) {
+		t.Fatalf("scores tag %v, user name %v, content %v; want tag > user name > content > 0", tag, name, content)
+	}
+	if s := searchScore(models.Post{Content: "turmeric"}, terms); s != 0 {
+		t.Fatalf("non-matching post scored %v", s)
+	}
+}
+
+func createSearchPosts(t *testing.T, repo PostRepository, n int) {
+	t.Helper()
+	base := time.Now()
+	for i := range n {
+		p := models.Post{UserID: "u", Section: models.SectionRemedies, Content: "ginger tea", CreatedAt: base.Add(-time.Duration(i) * time.Minute)}
+		if err := repo.Create(context.Background(), &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemorySearchCursor(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepositories().Posts
+	createSearchPosts(t, repo, 5)
+	best := models.Post{UserID: "u", Section: models.SectionRemedies, Tags: []string{"#ginger"}, CreatedAt: time.Now().Add(-time.Hour)}
+	if err := repo.Create(ctx, &best); err != nil {
+		t.Fatal(err)
+	}
+	other := models.Post{UserID: "u", Section: models.SectionRemedies, Content: "turmeric"}
+	if err := repo.Create(ctx, &other); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	q := SearchQuery{Text: "gingers", Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor never ran out")
+		}
+		page, err := repo.Search(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, h := range page.Hits {
+			got = append(got, h.Post.ID.Hex())
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if len(got) != 6 {
+		t.Fatalf("got %d hits, want 6", len(got))
+	}
+	if got[0] != best.ID.Hex() {
+		t.Fatalf("first hit = %s, want the tagged post %s", got[0], best.ID.Hex())
+	}
+	seen := map[string]bool{}
+	for _, id := range got {
+		if seen[id] || id == other.ID.Hex() {
+			t.Fatalf("unexpected hit %s in %v", id, got)
+		}
+		seen[id] = true
+	}
+}
+
+func TestMemorySearchMaxOffset(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepositories().Posts
+	createSearchPosts(t, repo, MaxSearchOffset+2*MaxSearchLimit)
+	q := SearchQuery{Text: "ginger", Limit: MaxSearchLimit}
+	total := 0
+	for {
+		page, err := repo.Search(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(page.Hits)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if want := MaxSearchOffset + MaxSearchLimit; total != want {
+		t.Fatalf("paged through %d hits, want %d before the cursor stops", total, want)
+	}
+}
+
+func TestSearchInvalidCursor(t *testing.T) {
+	repo := NewMemoryRepositories().Posts
+	past := base64.RawURLEncoding.EncodeToString([]byte(`{"o":1001}`))
+	negative := base64.RawURLEncoding.EncodeToString([]byte(`{"o":-1}`))
+	for _, c := range []string{"***", base64.RawURLEncoding.EncodeToString([]byte("nope")), past, negative} {
+		if _, err := repo.Search(context.Background(), SearchQuery{Text: "ginger", Cursor: c}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: err = %v, want ErrInvalidCursor", c, err)
+		}
+	}
+}
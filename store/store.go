@@ -8,35 +8,23 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var (
-	Client *mongo.Client
-	DB     *mongo.Database
-
-	UsersCollection *mongo.Collection
-	PostsCollection *mongo.Collection
-)
-
-func Init(ctx context.Context, uri, dbName string) error {
+// Init connects to MongoDB, pings it and returns the named database.
+func Init(ctx context.Context, uri, dbName string) (*mongo.Database, error) {
 	cl, err := mongo.NewClient(options.Client().ApplyURI(uri))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	ctx2, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	err = cl.Connect(ctx2)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// ping
 	ctx3, cancel2 := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel2()
 	if err := cl.Ping(ctx3, nil); err != nil {
-		return err
+		return nil, err
 	}
-
-	Client = cl
-	DB = cl.Database(dbName)
-	UsersCollection = DB.Collection("users")
-	PostsCollection = DB.Collection("posts")
-	return nil
+	return cl.Database(dbName), nil
 }
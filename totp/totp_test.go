@@ -0,0 +1,106 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed from RFC 6238 appendix B, "12345678901234567890".
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// The RFC's expected values are eight digits; six-digit codes are their
+// last six.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		got, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil || got != v.code {
+			t.Errorf("Code at %d = %q, %v; want %q", v.unix, got, err, v.code)
+		}
+	}
+}
+
+func TestCodeSecretForms(t *testing.T) {
+	// Secrets are often typed in lower case or with stray spaces.
+	want, _ := Code(rfcSecret, 1)
+	for _, s := range []string{" " + rfcSecret + " ", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq"} {
+		if got, err := Code(s, 1); err != nil || got != want {
+			t.Errorf("Code(%q) = %q, %v; want %q", s, got, err, want)
+		}
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("bad secret accepted")
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	for _, tc := range []struct {
+		offset int64
+		ok     bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	} {
+		code, _ := Code(rfcSecret, step+tc.offset)
+		got, ok := Validate(rfcSecret, code, now)
+		if ok != tc.ok || (ok && got != step+tc.offset) {
+			t.Errorf("code for step %+d: Validate = %d, %v; want step %d, %v", tc.offset, got, ok, step+tc.offset, tc.ok)
+		}
+	}
+	code, _ := Code(rfcSecret, step)
+	if _, ok := Validate(rfcSecret, code[:3]+" "+code[3:], now); !ok {
+		t.Error("code with a space rejected")
+	}
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := Validate(rfcSecret, bad, now); ok {
+			t.Errorf("Validate(%q) accepted", bad)
+		}
+	}
+}
+
+// Validate itself accepts a code for as long as its step is in the window;
+// refusing a replay relies on the step it returns, which callers record.
+func TestValidateReplayStep(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := Code(rfcSecret, Step(now))
+	first, ok := Validate(rfcSecret, code, now)
+	if !ok {
+		t.Fatal("code rejected")
+	}
+	again, ok := Validate(rfcSecret, code, now.Add(Period))
+	if !ok || again != first {
+		t.Fatalf("replay a step later: step %d, %v; want the same step %d so it can be refused", again, ok, first)
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Home Remedies", "ann@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Home Remedies:ann@example.com" {
+		t.Fatalf("uri = %v", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != rfcSecret || q.Get("issuer") != "Home Remedies" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("query = %v", q)
+	}
+}